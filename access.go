@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"net/netip"
 	"os"
+	"strings"
+	"time"
 )
 
 type PlayerBase struct {
@@ -48,13 +51,148 @@ func WritePlayerList(path string, player PlayerBase) []PlayerBase {
 	return list
 }
 
-func LoadIPBans() []string {
-	list := []string{}
+type OPEntry struct {
+	UUID                string `json:"uuid"`
+	Name                string `json:"name"`
+	Level               int    `json:"level"`
+	BypassesPlayerLimit bool   `json:"bypassesPlayerLimit"`
+}
+
+// older versions stored ops as {"id","name"} without a level
+func (entry *OPEntry) UnmarshalJSON(data []byte) error {
+	type opEntry OPEntry
+	var e struct {
+		opEntry
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	*entry = OPEntry(e.opEntry)
+	if entry.UUID == "" {
+		entry.UUID = e.ID
+	}
+	if entry.Level == 0 {
+		entry.Level = 4
+	}
+	return nil
+}
+
+func LoadOPList(path string) []OPEntry {
+	list := []OPEntry{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		file.Close()
+		file, _ := os.Create(path)
+		e := json.NewEncoder(file)
+		e.Encode(&list)
+		return list
+	}
+	defer file.Close()
+
+	d := json.NewDecoder(file)
+
+	if err := d.Decode(&list); err != nil {
+		return nil
+	}
+
+	return list
+}
+
+func WriteOPList(path string, list []OPEntry) {
+	data, _ := json.MarshalIndent(list, "", "  ")
+	os.WriteFile(path, data, 0755)
+}
+
+const BanTimeFormat = "2006-01-02 15:04:05 -0700"
+
+type BanEntry struct {
+	UUID    string `json:"uuid,omitempty"`
+	Name    string `json:"name,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+func NewBanEntry(source string, reason string, expires time.Time) BanEntry {
+	if reason == "" {
+		reason = "Banned by an operator."
+	}
+	entry := BanEntry{
+		Created: time.Now().Format(BanTimeFormat),
+		Source:  source,
+		Expires: "forever",
+		Reason:  reason,
+	}
+	if !expires.IsZero() {
+		entry.Expires = expires.Format(BanTimeFormat)
+	}
+	return entry
+}
+
+// older versions stored banned players as {"id","name"} and banned ips as plain strings
+func (entry *BanEntry) UnmarshalJSON(data []byte) error {
+	var ip string
+	if json.Unmarshal(data, &ip) == nil {
+		*entry = NewBanEntry("Server", "", time.Time{})
+		entry.IP = ip
+		return nil
+	}
+	type banEntry BanEntry
+	var e struct {
+		banEntry
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	*entry = BanEntry(e.banEntry)
+	if entry.UUID == "" {
+		entry.UUID = e.ID
+	}
+	if entry.Expires == "" {
+		entry.Expires = "forever"
+	}
+	if entry.Reason == "" {
+		entry.Reason = "Banned by an operator."
+	}
+	return nil
+}
+
+func (entry BanEntry) ExpiresAt() (time.Time, bool) {
+	if entry.Expires == "" || entry.Expires == "forever" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(BanTimeFormat, entry.Expires)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (entry BanEntry) Expired() bool {
+	t, temporary := entry.ExpiresAt()
+	return temporary && time.Now().After(t)
+}
+
+func (entry BanEntry) Message() string {
+	message := server.Config.Messages.Banned
+	if _, temporary := entry.ExpiresAt(); temporary {
+		message = server.Config.Messages.TempBanned
+	}
+	return ParsePlaceholders(message, Placeholders{Reason: entry.Reason, Expires: entry.Expires})
+}
+
+func LoadBanList(path string) []BanEntry {
+	list := []BanEntry{}
 
-	file, err := os.Open("banned_ips.json")
+	file, err := os.Open(path)
 	if err != nil {
 		file.Close()
-		file, _ := os.Create("banned_ips.json")
+		file, _ := os.Create(path)
 		e := json.NewEncoder(file)
 		e.Encode(&list)
 		return list
@@ -70,6 +208,153 @@ func LoadIPBans() []string {
 	return list
 }
 
+func WriteBanList(path string, list []BanEntry) {
+	data, _ := json.MarshalIndent(list, "", "  ")
+	os.WriteFile(path, data, 0755)
+}
+
+// the ban lists use the names of vanilla so they can be copied between servers
+const (
+	BannedPlayersFile = "banned-players.json"
+	BannedIPsFile     = "banned-ips.json"
+)
+
+// loads the ban lists, renaming the files of older versions to the vanilla names first
+func (players *PlayersC) LoadBans() {
+	for old, path := range map[string]string{"banned_players.json": BannedPlayersFile, "banned_ips.json": BannedIPsFile} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			os.Rename(old, path)
+		}
+	}
+	bannedPlayers, bannedIPs := LoadBanList(BannedPlayersFile), LoadBanList(BannedIPsFile)
+	players.Lock()
+	defer players.Unlock()
+	players.BannedPlayers = bannedPlayers
+	players.SetBannedIPs(bannedIPs)
+}
+
+// bans a player and saves the list, returns false if it's already banned
+func (players *PlayersC) AddPlayerBan(entry BanEntry) bool {
+	players.Lock()
+	defer players.Unlock()
+	if players.getBan(entry.UUID, "") != nil {
+		return false
+	}
+	players.BannedPlayers = append(players.BannedPlayers, entry)
+	WriteBanList(BannedPlayersFile, players.BannedPlayers)
+	return true
+}
+
+// bans an ip or range and saves the list, returns false if it's already banned
+func (players *PlayersC) AddIPBan(entry BanEntry) bool {
+	players.Lock()
+	defer players.Unlock()
+	// 10.0.0.1/8 and 10.0.0.0/8 are the same range
+	ip := entry.IP
+	if normalized, err := NormalizeIPBan(ip); err == nil {
+		ip = normalized
+	}
+	for _, e := range players.BannedIPs {
+		if normalized, err := NormalizeIPBan(e.IP); e.IP == ip || err == nil && normalized == ip {
+			return false
+		}
+	}
+	entry.IP = ip
+	players.SetBannedIPs(append(players.BannedIPs, entry))
+	WriteBanList(BannedIPsFile, players.BannedIPs)
+	return true
+}
+
+// pardons a player by uuid or name and saves the list, returns the removed ban
+func (players *PlayersC) RemovePlayerBan(id string) (bool, BanEntry) {
+	players.Lock()
+	defer players.Unlock()
+	for i, entry := range players.BannedPlayers {
+		if entry.UUID == id || strings.EqualFold(entry.Name, id) {
+			players.BannedPlayers = append(players.BannedPlayers[:i:i], players.BannedPlayers[i+1:]...)
+			WriteBanList(BannedPlayersFile, players.BannedPlayers)
+			return true, entry
+		}
+	}
+	return false, BanEntry{}
+}
+
+// pardons an ip or range and saves the list, returns false if it wasn't banned
+func (players *PlayersC) RemoveIPBan(ip string) bool {
+	players.Lock()
+	defer players.Unlock()
+	for i, entry := range players.BannedIPs {
+		if normalized, err := NormalizeIPBan(entry.IP); entry.IP == ip || err == nil && normalized == ip {
+			players.SetBannedIPs(append(players.BannedIPs[:i:i], players.BannedIPs[i+1:]...))
+			WriteBanList(BannedIPsFile, players.BannedIPs)
+			return true
+		}
+	}
+	return false
+}
+
+// removes expired bans and saves the lists if anything changed
+func (server *Server) PruneBans() {
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	players, ips := []BanEntry{}, []BanEntry{}
+	for _, entry := range server.Players.BannedPlayers {
+		if !entry.Expired() {
+			players = append(players, entry)
+		}
+	}
+	for _, entry := range server.Players.BannedIPs {
+		if !entry.Expired() {
+			ips = append(ips, entry)
+		}
+	}
+	if len(players) != len(server.Players.BannedPlayers) {
+		server.Players.BannedPlayers = players
+		WriteBanList(BannedPlayersFile, players)
+	}
+	if len(ips) != len(server.Players.BannedIPs) {
+		server.Players.SetBannedIPs(ips)
+		WriteBanList(BannedIPsFile, ips)
+	}
+}
+
+// returns a copy of the ban matching the player or ip, nil if there is none
+func (players *PlayersC) GetBan(id string, ip string) *BanEntry {
+	players.Lock()
+	defer players.Unlock()
+	if ban := players.getBan(id, ip); ban != nil {
+		entry := *ban
+		return &entry
+	}
+	return nil
+}
+
+// GetBan without locking the players
+func (players *PlayersC) getBan(id string, ip string) *BanEntry {
+	for i, entry := range players.BannedPlayers {
+		if id != "" && entry.UUID == id && !entry.Expired() {
+			return &players.BannedPlayers[i]
+		}
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || players.IPBanTree == nil {
+		return nil
+	}
+	// an expired ban doesn't hide an active one of a wider range
+	entries := players.IPBanTree.Lookup(addr)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !players.BannedIPs[entries[i]].Expired() {
+			return &players.BannedIPs[entries[i]]
+		}
+	}
+	return nil
+}
+
+func (players *PlayersC) SetBannedIPs(list []BanEntry) {
+	players.BannedIPs = list
+	players.IPBanTree = NewIPBanTree(list)
+}
+
 /*
 
 0: User is valid
@@ -78,18 +363,14 @@ func LoadIPBans() []string {
 3: Server is full
 4: User is already playing on another client
 
+the ban that matched is returned with 2
+
 */
 
-func ValidatePlayer(name string, id string, ip string) int {
-	for _, player := range server.Players.BannedPlayers {
-		if player.UUID == id {
-			return 2
-		}
-	}
-	for _, i := range server.Players.BannedIPs {
-		if i == ip {
-			return 2
-		}
+func ValidatePlayer(name string, id string, ip string) (int, *BanEntry) {
+	server.PruneBans()
+	if ban := server.Players.GetBan(id, ip); ban != nil {
+		return 2, ban
 	}
 	if server.Config.Whitelist.Enable {
 		d := false
@@ -100,17 +381,22 @@ func ValidatePlayer(name string, id string, ip string) int {
 			}
 		}
 		if !d {
-			return 1
+			return 1, nil
 		}
 	}
 	if server.Players.Players[id] != nil {
-		return 4
+		return 4, nil
 	}
 	if server.Config.MaxPlayers == -1 {
-		return 0
+		return 0, nil
 	}
 	if len(server.Players.Players) >= server.Config.MaxPlayers {
-		return 3
+		for _, op := range server.Players.OPs {
+			if op.UUID == id && op.BypassesPlayerLimit {
+				return 0, nil
+			}
+		}
+		return 3, nil
 	}
-	return 0
+	return 0, nil
 }
@@ -0,0 +1,69 @@
+// Package benchmarks holds benchmarks that don't need a world, the main package loads one when it starts
+package benchmarks
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Tnze/go-mc/data/packetid"
+	"github.com/Tnze/go-mc/level"
+	"github.com/Tnze/go-mc/level/block"
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+)
+
+// counts the bytes written to a connection
+type byteCounter int64
+
+func (counter *byteCounter) Write(p []byte) (int, error) {
+	*counter += byteCounter(len(p))
+	return len(p), nil
+}
+
+// a chunk with bedrock, stone with scattered ores up to y 63, a few layers of dirt and grass and air above,
+// generated from a fixed seed so the sizes can be compared between runs
+func benchmarkChunk() *level.Chunk {
+	chunk := level.EmptyChunk(24)
+	random := rand.New(rand.NewSource(1))
+	stone, dirt, grass, bedrock := block.ToStateID[block.Stone{}], block.ToStateID[block.Dirt{}], block.ToStateID[block.GrassBlock{}], block.ToStateID[block.Bedrock{}]
+	ores := []block.StateID{block.ToStateID[block.CoalOre{}], block.ToStateID[block.IronOre{}]}
+	for y := 0; y < 133; y++ {
+		section := &chunk.Sections[y/16]
+		for i := 0; i < 256; i++ {
+			state := stone
+			switch {
+			case y == 0:
+				state = bedrock
+			case y == 132:
+				state = grass
+			case y >= 128:
+				state = dirt
+			case random.Intn(50) == 0:
+				state = ores[random.Intn(len(ores))]
+			}
+			section.SetBlock(y%16*256+i, state)
+		}
+	}
+	return chunk
+}
+
+func benchmarkChunkPacket(b *testing.B, threshold int) {
+	packet := pk.Marshal(packetid.ClientboundLevelChunkWithLight, level.ChunkPos{0, 0}, benchmarkChunk())
+	var counter byteCounter
+	conn := net.Conn{Writer: &counter}
+	conn.SetThreshold(threshold)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.WritePacket(packet)
+	}
+	b.ReportMetric(float64(counter)/float64(b.N), "bytes/chunk")
+}
+
+// go test -bench ChunkPacket -run ^$ ./benchmarks compares the size and time of chunk packets with and without compression
+func BenchmarkChunkPacketUncompressed(b *testing.B) {
+	benchmarkChunkPacket(b, -1)
+}
+
+func BenchmarkChunkPacketCompressed(b *testing.B) {
+	benchmarkChunkPacket(b, 256)
+}
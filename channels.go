@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Tnze/go-mc/chat"
+	"github.com/Tnze/go-mc/data/packetid"
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+)
+
+const (
+	ChannelBrand      = "minecraft:brand"
+	ChannelRegister   = "minecraft:register"
+	ChannelUnregister = "minecraft:unregister"
+	// like Paper, clients that register more channels or longer names are kicked
+	MaxPlayerChannels    = 128
+	MaxChannelNameLength = 256
+)
+
+// ChannelHandler handles a plugin message a player sent on a channel
+type ChannelHandler func(player *Player, data []byte)
+
+// LoginQuery is sent to every client as a login plugin request before the login succeeds,
+// Handle gets the answer and returns false with a reason to refuse the login
+type LoginQuery struct {
+	Data   []byte
+	Handle func(name string, understood bool, data []byte) (bool, string)
+}
+
+// Channels holds the handlers for plugin channels of Go code and plugins
+type Channels struct {
+	sync.RWMutex
+	handlers map[string]ChannelHandler
+	queries  map[string]LoginQuery
+}
+
+func NewChannels() *Channels {
+	return &Channels{handlers: make(map[string]ChannelHandler), queries: make(map[string]LoginQuery)}
+}
+
+// registers handler for messages on channel, replacing the previous one
+func (channels *Channels) Register(channel string, handler ChannelHandler) {
+	channels.Lock()
+	defer channels.Unlock()
+	channels.handlers[channel] = handler
+}
+
+func (channels *Channels) Unregister(channel string) {
+	channels.Lock()
+	defer channels.Unlock()
+	delete(channels.handlers, channel)
+}
+
+func (channels *Channels) RegisterLoginQuery(channel string, query LoginQuery) {
+	channels.Lock()
+	defer channels.Unlock()
+	channels.queries[channel] = query
+}
+
+func (channels *Channels) UnregisterLoginQuery(channel string) {
+	channels.Lock()
+	defer channels.Unlock()
+	delete(channels.queries, channel)
+}
+
+// returns the sorted names of the channels the server listens on
+func (channels *Channels) Names() []string {
+	channels.RLock()
+	defer channels.RUnlock()
+	names := make([]string, 0, len(channels.handlers))
+	for name := range channels.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handles a plugin message from player, the channels the client registers are tracked here
+func (channels *Channels) Handle(player *Player, channel string, data []byte) {
+	switch channel {
+	case ChannelBrand:
+		{
+			var brand pk.String
+			if _, err := brand.ReadFrom(bytes.NewReader(data)); err == nil {
+				player.Client.Brand = brand
+			}
+		}
+	case ChannelRegister:
+		{
+			player.Lock()
+			valid := true
+			for _, name := range splitChannels(data) {
+				if len(name) > MaxChannelNameLength {
+					valid = false
+					break
+				}
+				player.Channels[name] = struct{}{}
+			}
+			valid = valid && len(player.Channels) <= MaxPlayerChannels
+			player.Unlock()
+			if !valid {
+				server.Logger.Info("[%s] Player %s (%s) was kicked. reason: %s", player.IP, player.Name, player.UUID.String, server.Config.Messages.TooManyChannels)
+				server.Kick(player.UUID.String, chat.Text(server.Config.Messages.TooManyChannels))
+				return
+			}
+		}
+	case ChannelUnregister:
+		{
+			player.Lock()
+			for _, name := range splitChannels(data) {
+				delete(player.Channels, name)
+			}
+			player.Unlock()
+		}
+	}
+	channels.RLock()
+	handler := channels.handlers[channel]
+	channels.RUnlock()
+	if handler != nil {
+		handler(player, data)
+	}
+}
+
+// sends every login query to the client and waits for all answers, returns false with a reason if the login should be refused
+func (channels *Channels) RunLoginQueries(conn *net.Conn, name string) (bool, string) {
+	channels.RLock()
+	queries := make(map[pk.VarInt]LoginQuery, len(channels.queries))
+	messageID := pk.VarInt(rand.Int31n(1 << 30))
+	for channel, query := range channels.queries {
+		messageID++
+		queries[messageID] = query
+		conn.WritePacket(pk.Marshal(packetid.LoginPluginRequest,
+			messageID,
+			pk.Identifier(channel),
+			pk.PluginMessageData(query.Data),
+		))
+	}
+	channels.RUnlock()
+	for len(queries) > 0 {
+		var p pk.Packet
+		if err := conn.ReadPacket(&p); err != nil {
+			return false, err.Error()
+		}
+		var (
+			id         pk.VarInt
+			understood pk.Boolean
+			data       pk.PluginMessageData
+		)
+		if p.ID != packetid.LoginPluginResponse || p.Scan(&id, &understood, &data) != nil {
+			return false, "invalid login plugin response"
+		}
+		query, ok := queries[id]
+		if !ok {
+			return false, fmt.Sprintf("unknown login plugin message id %d", id)
+		}
+		delete(queries, id)
+		if ok, reason := query.Handle(name, bool(understood), data); !ok {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+func splitChannels(data []byte) []string {
+	var names []string
+	for _, name := range strings.Split(string(data), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// reports whether the client registered channel with minecraft:register
+func (player *Player) ListensOn(channel string) bool {
+	player.Lock()
+	defer player.Unlock()
+	_, ok := player.Channels[channel]
+	return ok
+}
+
+func (player *Player) SendPluginMessage(channel string, data []byte) error {
+	return player.Connection.WritePacket(pk.Marshal(packetid.ClientboundCustomPayload, pk.Identifier(channel), pk.PluginMessageData(data)))
+}
+
+// tells the client the brand of the server and which channels it listens on
+func (player *Player) SendChannels() {
+	var brand bytes.Buffer
+	pk.String("Dynamite").WriteTo(&brand)
+	player.SendPluginMessage(ChannelBrand, brand.Bytes())
+	if names := server.Channels.Names(); len(names) > 0 {
+		player.SendPluginMessage(ChannelRegister, []byte(strings.Join(names, "\x00")))
+	}
+}
+
+// sends a plugin message to every player whose client registered channel
+func (server Server) BroadcastPluginMessage(channel string, data []byte) {
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	for _, player := range server.Players.Players {
+		if player.ListensOn(channel) {
+			player.SendPluginMessage(channel, data)
+		}
+	}
+}
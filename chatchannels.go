@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Tnze/go-mc/chat"
+)
+
+const (
+	CHAT_CHANNEL_GLOBAL = "global"
+	CHAT_CHANNEL_LOCAL  = "local"
+	CHAT_CHANNEL_WORLD  = "world"
+)
+
+var chatChannelActions = []string{"join", "leave", "focus", "list", "say"}
+
+func FindChatChannel(name string) (bool, ChatChannel) {
+	for _, channel := range server.Config.Chat.Channels {
+		if strings.EqualFold(channel.Name, name) {
+			return true, channel
+		}
+	}
+	return false, ChatChannel{}
+}
+
+func (server Server) CanUseChatChannel(id string, channel ChatChannel) bool {
+	return channel.Permission == "" || server.HasPermissions(id, []string{channel.Permission})
+}
+
+// joins the channels players are in by default and focuses the default channel
+func (player *Player) InitChatChannels() {
+	player.Lock()
+	defer player.Unlock()
+	player.ChatChannels = make(map[string]bool)
+	for _, channel := range server.Config.Chat.Channels {
+		if channel.AutoJoin && server.CanUseChatChannel(player.UUID.String, channel) {
+			player.ChatChannels[channel.Name] = true
+		}
+	}
+	player.FocusedChannel = ""
+	if exists, channel := FindChatChannel(server.Config.Chat.DefaultChannel); exists && server.CanUseChatChannel(player.UUID.String, channel) {
+		player.ChatChannels[channel.Name] = true
+		player.FocusedChannel = channel.Name
+	}
+}
+
+func (player *Player) InChatChannel(name string) bool {
+	player.Lock()
+	defer player.Unlock()
+	return player.ChatChannels[name]
+}
+
+func (player *Player) SetChatChannel(name string, joined bool) {
+	player.Lock()
+	defer player.Unlock()
+	if joined {
+		player.ChatChannels[name] = true
+	} else {
+		delete(player.ChatChannels, name)
+	}
+}
+
+func (player *Player) FocusChatChannel(name string) {
+	player.Lock()
+	defer player.Unlock()
+	player.ChatChannels[name] = true
+	player.FocusedChannel = name
+}
+
+// returns the channel the player talks in, or "" if there are no channels
+func (player *Player) ChatChannel() string {
+	player.Lock()
+	defer player.Unlock()
+	return player.FocusedChannel
+}
+
+// reports whether recipient receives a message sender sent in the channel, sender is nil for the console
+func (channel ChatChannel) Reaches(sender *Player, recipient *Player) bool {
+	if !recipient.InChatChannel(channel.Name) || !server.CanUseChatChannel(recipient.UUID.String, channel) {
+		return false
+	}
+	switch channel.Type {
+	case CHAT_CHANNEL_LOCAL:
+		{
+			if sender == nil {
+				return true
+			}
+			if sender.Data.Dimension != recipient.Data.Dimension {
+				return false
+			}
+			dx := sender.Position[0] - recipient.Position[0]
+			dy := sender.Position[1] - recipient.Position[1]
+			dz := sender.Position[2] - recipient.Position[2]
+			return math.Sqrt(dx*dx+dy*dy+dz*dz) <= channel.Radius
+		}
+	case CHAT_CHANNEL_WORLD:
+		{
+			if channel.World != "" {
+				return recipient.Data.Dimension == channel.World
+			}
+			return sender == nil || sender.Data.Dimension == recipient.Data.Dimension
+		}
+	}
+	return true
+}
+
+// returns the ids of the players that receive a message sent in channel, the players have to be locked,
+// everyone receives it if there are no channels and nobody if the channel doesn't exist
+func (server Server) ChatChannelRecipients(channel string, sender *Player) []string {
+	ids := []string{}
+	channels := len(server.Config.Chat.Channels) > 0
+	exists, c := FindChatChannel(channel)
+	if channels && !exists {
+		return ids
+	}
+	for id, player := range server.Players.Players {
+		if !channels || c.Reaches(sender, player) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func chatChannelDisplayName(name string) string {
+	if exists, channel := FindChatChannel(name); exists && channel.DisplayName != "" {
+		return channel.DisplayName
+	}
+	return name
+}
+
+// sends a message from the console or rcon to everyone in a channel
+func (server Server) ChatChannelMessage(executor string, channel ChatChannel, message string) {
+	content := FormatPlaceholders(TranslateColorCodes(server.Config.Chat.Format), Placeholders{PlayerName: server.GetName(executor), Message: message, Channel: chatChannelDisplayName(channel.Name)}, server.AllowedTags(executor))
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	server.Logger.Print("[%s] %s", channel.Name, content.String())
+	for _, id := range server.ChatChannelRecipients(channel.Name, nil) {
+		server.Message(id, content)
+	}
+}
+
+func chatChannelNames(executor string) []string {
+	names := []string{}
+	for _, channel := range server.Config.Chat.Channels {
+		if server.CanUseChatChannel(executor, channel) {
+			names = append(names, channel.Name)
+		}
+	}
+	return names
+}
+
+func (server *Server) ChatChannelCommand(executor string, executorPlayer *Player, args []string) chat.Message {
+	action := GetArgument(args, 0)
+	if action == "list" {
+		lines := []string{"§eChat channels:"}
+		for _, name := range chatChannelNames(executor) {
+			line := "§7- " + name
+			if executorPlayer != nil && executorPlayer.ChatChannel() == name {
+				line = "§a- " + name + " (focused)"
+			} else if executorPlayer != nil && executorPlayer.InChatChannel(name) {
+				line = "§f- " + name + " (joined)"
+			}
+			lines = append(lines, line)
+		}
+		return chat.Text(strings.Join(lines, "\n"))
+	}
+	exists, channel := FindChatChannel(GetArgument(args, 1))
+	if !exists || !server.CanUseChatChannel(executor, channel) {
+		return chat.Text("§cUnknown channel")
+	}
+	if action == "say" {
+		if executorPlayer != nil {
+			return chat.Text(fmt.Sprintf("§cUse /channel focus %s to talk in this channel", channel.Name))
+		}
+		if len(args) < 3 {
+			return chat.Text("§cPlease specify a message")
+		}
+		server.ChatChannelMessage(executor, channel, strings.Join(args[2:], " "))
+		return chat.Message{}
+	}
+	if executorPlayer == nil {
+		return chat.Text("§cThe console reads every channel, use /channel say to talk in one")
+	}
+	switch action {
+	case "join":
+		{
+			executorPlayer.SetChatChannel(channel.Name, true)
+			return chat.Text(fmt.Sprintf("Joined channel %s", channel.Name))
+		}
+	case "leave":
+		{
+			if executorPlayer.ChatChannel() == channel.Name {
+				return chat.Text("§cYou can't leave the channel you are talking in")
+			}
+			executorPlayer.SetChatChannel(channel.Name, false)
+			return chat.Text(fmt.Sprintf("Left channel %s", channel.Name))
+		}
+	case "focus":
+		{
+			executorPlayer.FocusChatChannel(channel.Name)
+			return chat.Text(fmt.Sprintf("You are now talking in %s", channel.Name))
+		}
+	}
+	return chat.Text("§cPlease specify join, leave, focus, list or say")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/Tnze/go-mc/chat/sign"
+	"github.com/Tnze/go-mc/data/packetid"
+	pk "github.com/Tnze/go-mc/net/packet"
+)
+
+// how many of the last signed messages can be deleted
+const ChatHistorySize = 256
+
+type chatHistoryEntry struct {
+	ID        int
+	Sender    string
+	Signature *sign.Signature
+}
+
+// ChatHistory remembers the last signed messages so moderators can delete them by id
+type ChatHistory struct {
+	sync.Mutex
+	entries []chatHistoryEntry
+	lastID  int
+}
+
+var chatHistory = &ChatHistory{}
+
+// remembers a signed message and returns its id
+func (history *ChatHistory) Add(sender *Player, signature *sign.Signature) int {
+	history.Lock()
+	defer history.Unlock()
+	history.lastID++
+	history.entries = append(history.entries, chatHistoryEntry{ID: history.lastID, Sender: sender.Name, Signature: signature})
+	if len(history.entries) > ChatHistorySize {
+		history.entries = history.entries[len(history.entries)-ChatHistorySize:]
+	}
+	return history.lastID
+}
+
+// forgets the message with id and returns it
+func (history *ChatHistory) Remove(id int) (bool, chatHistoryEntry) {
+	history.Lock()
+	defer history.Unlock()
+	for i, entry := range history.entries {
+		if entry.ID == id {
+			history.entries = append(history.entries[:i], history.entries[i+1:]...)
+			return true, entry
+		}
+	}
+	return false, chatHistoryEntry{}
+}
+
+// removes the message with signature from the chat of every player
+func (server Server) DeleteMessage(signature *sign.Signature) {
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	for _, player := range server.Players.Players {
+		player.Connection.WritePacket(pk.Marshal(packetid.ClientboundDeleteChat, player.Chat.PackSignature(signature)))
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
+	"math/rand"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Tnze/go-mc/chat"
 	"github.com/Tnze/go-mc/data/packetid"
@@ -20,10 +22,11 @@ func Reload() chat.Message {
 	groupCache = make(map[string]GroupPermissions)
 	server.Config = LoadConfig()
 	server.Players.Whitelist = LoadPlayerList("whitelist.json")
-	server.Players.OPs = LoadPlayerList("ops.json")
-	server.Players.BannedPlayers = LoadPlayerList("banned_players.json")
-	server.Players.BannedIPs = LoadIPBans()
+	server.Players.OPs = LoadOPList("ops.json")
+	server.Players.LoadBans()
+	server.Players.LoadMutes()
 	server.Favicon = []byte{}
+	InvalidateStatusCache()
 	if server.Config.Whitelist.Enable && server.Config.Whitelist.Enforce {
 		wmap := make(map[string]bool)
 		for _, p := range server.Players.Whitelist {
@@ -42,16 +45,166 @@ func Reload() chat.Message {
 	return chat.Text(server.Config.Messages.ReloadComplete)
 }
 
-func CreateSTDINReader() {
-	reader := bufio.NewReader(os.Stdin)
-	command, _ := reader.ReadString('\n')
-	command = strings.TrimSpace(command)
-	if command == "" {
-		go CreateSTDINReader()
-		return
+// returns the command with the specified name or alias
+func (server *Server) FindCommand(name string) (Command, bool) {
+	if command, ok := server.Commands[name]; ok {
+		return command, true
 	}
-	server.Logger.Print("%v", server.Command("console", command))
-	go CreateSTDINReader()
+	for _, command := range server.Commands {
+		for _, alias := range command.Aliases {
+			if alias == name {
+				return command, true
+			}
+		}
+	}
+	return Command{}, false
+}
+
+// returns the usage string of the command, for example /teleport <one> [two]
+func (command Command) Usage() string {
+	usage := "/" + command.Name
+	for _, argument := range command.Arguments {
+		if argument.Optional {
+			usage += fmt.Sprintf(" [%s]", argument.Name)
+		} else {
+			usage += fmt.Sprintf(" <%s>", argument.Name)
+		}
+	}
+	return usage
+}
+
+func onlinePlayerNames(executor string) []string {
+	names := []string{"@a", "@p", "@r", "@s"}
+	for _, player := range server.Players.Players {
+		names = append(names, player.Name)
+	}
+	return names
+}
+
+func bannedPlayerNames(executor string) []string {
+	names := []string{}
+	for _, entry := range server.Players.BannedPlayers {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func bannedIPs(executor string) []string {
+	ips := []string{}
+	for _, entry := range server.Players.BannedIPs {
+		ips = append(ips, entry.IP)
+	}
+	return ips
+}
+
+func commandNames(executor string) []string {
+	names := []string{}
+	for name, command := range server.Commands {
+		if !server.HasPermissions(executor, command.RequiredPermissions) {
+			continue
+		}
+		names = append(names, name)
+		names = append(names, command.Aliases...)
+	}
+	return names
+}
+
+// suggestion providers by "<command> <argument>", used before the providers by parser name
+var argumentSuggestions = map[string]func(executor string) []string{
+	"help command":     commandNames,
+	"pardon player":    bannedPlayerNames,
+	"pardon-ip target": bannedIPs,
+	"unmute player":    mutedPlayerNames,
+	"channel action": func(string) []string {
+		return chatChannelActions
+	},
+	"channel channel": chatChannelNames,
+	"ban-ip target":   onlinePlayerNames,
+}
+
+var parserSuggestions = map[string]func(executor string) []string{
+	"minecraft:entity":       onlinePlayerNames,
+	"minecraft:game_profile": onlinePlayerNames,
+	"minecraft:gamemode": func(string) []string {
+		return gamemodes
+	},
+}
+
+// returns the suggestions for the last word of a command line (without the leading slash) and the index that word starts at
+func (server *Server) Suggest(executor string, line string) (int, []string) {
+	args := strings.Split(line, " ")
+	start := len(line) - len(args[len(args)-1])
+	var candidates []string
+	if len(args) == 1 {
+		candidates = commandNames(executor)
+	} else {
+		command, exists := server.FindCommand(args[0])
+		index := len(args) - 2
+		if !exists || !server.HasPermissions(executor, command.RequiredPermissions) || index >= len(command.Arguments) {
+			return start, nil
+		}
+		argument := command.Arguments[index]
+		if provider, ok := argumentSuggestions[command.Name+" "+argument.Name]; ok {
+			candidates = provider(executor)
+		} else if provider, ok := parserSuggestions[argument.Parser.Name]; ok {
+			candidates = provider(executor)
+		}
+	}
+	prefix := strings.ToLower(args[len(args)-1])
+	suggestions := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), prefix) {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	sort.Strings(suggestions)
+	return start, suggestions
+}
+
+const HelpPageSize = 8
+
+func (server *Server) Help(executor string, arg string) chat.Message {
+	names := []string{}
+	for name, command := range server.Commands {
+		if server.HasPermissions(executor, command.RequiredPermissions) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	page := 1
+	if arg != "" {
+		p, err := strconv.Atoi(arg)
+		if err != nil {
+			command, exists := server.FindCommand(strings.TrimPrefix(arg, "/"))
+			if !exists || !server.HasPermissions(executor, command.RequiredPermissions) {
+				return chat.Text(server.Config.Messages.UnknownCommand)
+			}
+			help := fmt.Sprintf("§e%s", command.Usage())
+			if len(command.Aliases) > 0 {
+				help += fmt.Sprintf("\n§7Aliases: %s", strings.Join(command.Aliases, ", "))
+			}
+			return chat.Text(help)
+		}
+		page = p
+	}
+	pages := (len(names) + HelpPageSize - 1) / HelpPageSize
+	if page < 1 || page > pages {
+		return chat.Text(fmt.Sprintf("§cPage must be between 1 and %d", pages))
+	}
+	lines := []string{fmt.Sprintf("§e--- Showing help page %d of %d (/help <page>) ---", page, pages)}
+	end := page * HelpPageSize
+	if end > len(names) {
+		end = len(names)
+	}
+	for _, name := range names[(page-1)*HelpPageSize : end] {
+		command := server.Commands[name]
+		line := command.Usage()
+		if len(command.Aliases) > 0 {
+			line += fmt.Sprintf(" §7(%s)", strings.Join(command.Aliases, ", "))
+		}
+		lines = append(lines, line)
+	}
+	return chat.Text(strings.Join(lines, "\n"))
 }
 
 func GetArgument(args []string, index int) string {
@@ -61,11 +214,81 @@ func GetArgument(args []string, index int) string {
 	return args[index]
 }
 
+// returns the offline or online player with the specified name or uuid
+func (server *Server) FindPlayerBase(id string) (bool, PlayerBase) {
+	player := PlayerBase{}
+	if _, err := uuid.Parse(id); err == nil { // is uuid
+		player.UUID = id
+		if p := server.Players.Players[id]; p != nil {
+			player.Name = p.Name
+		} else {
+			exists, p := server.Mojang.FetchUUID(id)
+			if !exists {
+				if server.Config.Online {
+					return false, player
+				}
+				player.Name = id
+			} else {
+				player.Name = p.Name
+			}
+		}
+	} else {
+		player.Name = id
+		if server.Players.PlayerNames[id] != "" {
+			player.UUID = server.Players.PlayerNames[id]
+		} else {
+			exists, p := server.Mojang.FetchUsername(id)
+			if !exists {
+				if server.Config.Online {
+					return false, player
+				}
+				player.UUID = fmt.Sprint(offline.NameToUUID(id))
+			} else {
+				player.UUID = p.UUID
+				player.Name = p.Name
+			}
+		}
+	}
+	return true, player
+}
+
+// parses durations like 30m, 12h or 1w2d
+func ParseDuration(str string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	var duration time.Duration
+	number := ""
+	for i := 0; i < len(str); i++ {
+		if str[i] >= '0' && str[i] <= '9' {
+			number += string(str[i])
+			continue
+		}
+		unit, ok := units[str[i]]
+		if !ok || number == "" {
+			return 0, fmt.Errorf("invalid duration %s", str)
+		}
+		n, _ := strconv.Atoi(number)
+		duration += time.Duration(n) * unit
+		number = ""
+	}
+	if number != "" || duration == 0 {
+		return 0, fmt.Errorf("invalid duration %s", str)
+	}
+	return duration, nil
+}
+
 func (server *Server) Command(executor string, content string) chat.Message {
 	var executorName string
 	var executorPlayer *Player
 	if executor == "console" {
 		executorName = "Console"
+	} else if executor == "rcon" {
+		executorName = "Rcon"
 	} else {
 		executorName = server.Players.Players[executor].Name
 		executorPlayer = server.Players.Players[executor]
@@ -74,16 +297,36 @@ func (server *Server) Command(executor string, content string) chat.Message {
 	args := strings.Split(content, " ")
 	cmd := args[0]
 	args = args[1:]
-	command, exists := server.Commands[cmd]
+	command, exists := server.FindCommand(cmd)
 	if !exists {
 		return chat.Text(server.Config.Messages.UnknownCommand)
 	}
 	if !server.HasPermissions(executor, command.RequiredPermissions) {
 		return chat.Text(server.Config.Messages.InsufficientPermissions)
 	}
-	switch cmd {
+	// muted players can't talk through commands either, and the messages go through the filters and anti-spam
+	switch command.Name {
+	case "msg", "r", "me", "say":
+		if mute := server.Players.GetMute(executor); mute != nil {
+			return chat.Text(mute.MuteMessage())
+		}
+		start := 0
+		if command.Name == "msg" {
+			start = 1
+		}
+		if len(args) > start {
+			ok, message := server.ModerateCommand(executor, strings.Join(args[start:], " "))
+			if !ok {
+				return chat.Text(message)
+			}
+			args = append(args[:start], strings.Split(message, " ")...)
+		}
+	}
+	switch cmd = command.Name; cmd {
 	case "reload", "rl":
 		return Reload()
+	case "help":
+		return server.Help(executor, GetArgument(args, 0))
 	case "stop":
 		{
 			go func() {
@@ -101,6 +344,7 @@ func (server *Server) Command(executor string, content string) chat.Message {
 						world.UnloadChunk(pos)
 					}
 				}
+				server.Console.Restore()
 				os.Exit(0)
 			}()
 			return chat.Text("Shutting down server...")
@@ -111,95 +355,334 @@ func (server *Server) Command(executor string, content string) chat.Message {
 			if id == "" {
 				return chat.Text("§cPlease specify a player to op")
 			}
-			isOp, op := server.Players.IsOP(id)
-			if isOp {
+			level := server.Config.OPPermissionLevel
+			if l := GetArgument(args, 1); l != "" {
+				var err error
+				level, err = strconv.Atoi(l)
+				if err != nil || level < 1 || level > 4 {
+					return chat.Text("§cThe op level must be between 1 and 4")
+				}
+			}
+			// ops can only manage ops below their own level
+			executorLevel := server.OPManagerLevel(executor)
+			if level >= executorLevel {
+				return chat.Text(fmt.Sprintf("§cYou can only give op levels below %d", executorLevel))
+			}
+			exists, player := server.FindPlayerBase(id)
+			if !exists {
+				return chat.Text("§cUnknown player")
+			}
+			isOp, op := server.Players.IsOP(player.UUID)
+			if isOp && op.Level >= executorLevel {
+				return chat.Text(fmt.Sprintf("§cYou can't change the op level of %s", op.Name))
+			}
+			if isOp && op.Level == level {
 				return chat.Text(fmt.Sprintf("§c%s is already a server operator", op.Name))
 			}
-			player := PlayerBase{}
-			if _, err := uuid.Parse(id); err == nil { // is uuid
-				player.UUID = id
-				if server.Players.Players[id].UUID.String == id {
-					player.Name = server.Players.Players[id].Name
-				} else {
-					exists, p := server.Mojang.FetchUUID(id)
-					if !exists {
-						if server.Config.Online {
-							return chat.Text("§cUnknown player")
-						}
-						player.Name = id
-					} else {
-						player.Name = p.Name
-					}
-				}
-			} else {
-				player.Name = id
-				if server.Players.PlayerNames[id] != "" {
-					player.UUID = server.Players.PlayerNames[id]
-				} else {
-					exists, p := server.Mojang.FetchUsername(id)
-					if !exists {
-						if server.Config.Online {
-							return chat.Text("§cUnknown player")
-						} else {
-							player.UUID = fmt.Sprint(offline.NameToUUID(id))
-						}
-					} else {
-						player.UUID = p.UUID
-					}
+			ops := []OPEntry{}
+			for _, op := range server.Players.OPs {
+				if op.UUID != player.UUID {
+					ops = append(ops, op)
 				}
 			}
-			server.Players.OPs = WritePlayerList("ops.json", player)
+			server.Players.OPs = append(ops, OPEntry{
+				UUID:                player.UUID,
+				Name:                player.Name,
+				Level:               level,
+				BypassesPlayerLimit: op.BypassesPlayerLimit,
+			})
+			WriteOPList("ops.json", server.Players.OPs)
 			if server.Players.Players[player.UUID] != nil {
 				server.Players.Players[player.UUID].Connection.WritePacket(pk.Marshal(packetid.ClientboundCommands, CommandGraph{player.UUID}))
 			}
-			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Made %s a server operator]", executorName, player.Name)))
-			return chat.Text(fmt.Sprintf("Made %s a server operator", player.Name))
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Made %s a level %d server operator]", executorName, player.Name, level)))
+			return chat.Text(fmt.Sprintf("Made %s a level %d server operator", player.Name, level))
+		}
+	case "deop":
+		{
+			id := GetArgument(args, 0)
+			if id == "" {
+				return chat.Text("§cPlease specify a player to deop")
+			}
+			isOp, op := server.Players.IsOP(id)
+			if !isOp {
+				return chat.Text(fmt.Sprintf("§c%s is not a server operator", id))
+			}
+			if op.Level >= server.OPManagerLevel(executor) {
+				return chat.Text(fmt.Sprintf("§cYou can't deop %s", op.Name))
+			}
+			ops := []OPEntry{}
+			for _, o := range server.Players.OPs {
+				if o.UUID != op.UUID {
+					ops = append(ops, o)
+				}
+			}
+			server.Players.OPs = ops
+			WriteOPList("ops.json", ops)
+			if server.Players.Players[op.UUID] != nil {
+				server.Players.Players[op.UUID].Connection.WritePacket(pk.Marshal(packetid.ClientboundCommands, CommandGraph{op.UUID}))
+			}
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Made %s no longer a server operator]", executorName, op.Name)))
+			return chat.Text(fmt.Sprintf("Made %s no longer a server operator", op.Name))
+		}
+	case "ban", "tempban":
+		{
+			id := GetArgument(args, 0)
+			if id == "" {
+				return chat.Text("§cPlease specify a player to ban")
+			}
+			var expires time.Time
+			reasonIndex := 1
+			if cmd == "tempban" {
+				duration, err := ParseDuration(GetArgument(args, 1))
+				if err != nil {
+					return chat.Text("§cPlease specify a valid duration, for example 30m, 12h or 7d")
+				}
+				expires = time.Now().Add(duration)
+				reasonIndex = 2
+			}
+			exists, player := server.FindPlayerBase(id)
+			if !exists {
+				return chat.Text("§cUnknown player")
+			}
+			var reason string
+			if len(args) > reasonIndex {
+				reason = strings.Join(args[reasonIndex:], " ")
+			}
+			entry := NewBanEntry(executorName, reason, expires)
+			entry.UUID = player.UUID
+			entry.Name = player.Name
+			if !server.Players.AddPlayerBan(entry) {
+				return chat.Text(fmt.Sprintf("§c%s is already banned", player.Name))
+			}
+			server.Kick(player.UUID, chat.Text(entry.Message()))
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Banned %s: %s]", executorName, player.Name, entry.Reason)))
+			return chat.Text(fmt.Sprintf("Banned %s: %s", player.Name, entry.Reason))
+		}
+	case "ban-ip":
+		{
+			target := GetArgument(args, 0)
+			if target == "" {
+				return chat.Text("§cPlease specify an IP address, range or player to ban")
+			}
+			ip, err := NormalizeIPBan(target)
+			if err != nil {
+				is, player := ParseTarget(executorPlayer, target)
+				if !is || player == nil {
+					return chat.Text("§cInvalid IP address or unknown player")
+				}
+				ip = RemoteAddress(player.IP)
+			}
+			var reason string
+			if len(args) > 1 {
+				reason = strings.Join(args[1:], " ")
+			}
+			entry := NewBanEntry(executorName, reason, time.Time{})
+			entry.IP = ip
+			if !server.Players.AddIPBan(entry) {
+				return chat.Text("§cThis IP is already banned")
+			}
+			// the players are collected under the lock and kicked after it, kicking writes to their sockets
+			kicked := make(map[string]string)
+			server.Players.Lock()
+			for id, player := range server.Players.Players {
+				if ban := server.Players.getBan("", RemoteAddress(player.IP)); ban != nil {
+					kicked[id] = ban.Message()
+				}
+			}
+			server.Players.Unlock()
+			for id, message := range kicked {
+				server.Kick(id, chat.Text(message))
+			}
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Banned IP %s: %s]", executorName, ip, entry.Reason)))
+			return chat.Text(fmt.Sprintf("Banned IP %s: %s", ip, entry.Reason))
+		}
+	case "pardon":
+		{
+			id := GetArgument(args, 0)
+			if id == "" {
+				return chat.Text("§cPlease specify a player to pardon")
+			}
+			ok, pardoned := server.Players.RemovePlayerBan(id)
+			if !ok {
+				return chat.Text(fmt.Sprintf("§c%s is not banned", id))
+			}
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Unbanned %s]", executorName, pardoned.Name)))
+			return chat.Text(fmt.Sprintf("Unbanned %s", pardoned.Name))
+		}
+	case "pardon-ip":
+		{
+			ip := GetArgument(args, 0)
+			if ip == "" {
+				return chat.Text("§cPlease specify an IP address to pardon")
+			}
+			if normalized, err := NormalizeIPBan(ip); err == nil {
+				ip = normalized
+			}
+			if !server.Players.RemoveIPBan(ip) {
+				return chat.Text("§cThis IP is not banned")
+			}
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Unbanned IP %s]", executorName, ip)))
+			return chat.Text(fmt.Sprintf("Unbanned IP %s", ip))
+		}
+	case "mute", "tempmute":
+		{
+			id := GetArgument(args, 0)
+			if id == "" {
+				return chat.Text("§cPlease specify a player to mute")
+			}
+			var expires time.Time
+			reasonIndex := 1
+			if cmd == "tempmute" {
+				duration, err := ParseDuration(GetArgument(args, 1))
+				if err != nil {
+					return chat.Text("§cPlease specify a valid duration, for example 30m, 12h or 7d")
+				}
+				expires = time.Now().Add(duration)
+				reasonIndex = 2
+			}
+			exists, player := server.FindPlayerBase(id)
+			if !exists {
+				return chat.Text("§cUnknown player")
+			}
+			server.PruneMutes()
+			var reason string
+			if len(args) > reasonIndex {
+				reason = strings.Join(args[reasonIndex:], " ")
+			}
+			entry := NewMuteEntry(executorName, reason, expires)
+			entry.UUID = player.UUID
+			entry.Name = player.Name
+			if !server.Players.AddMute(entry) {
+				return chat.Text(fmt.Sprintf("§c%s is already muted", player.Name))
+			}
+			if server.Players.Players[player.UUID] != nil {
+				server.Message(player.UUID, chat.Text(entry.MuteMessage()))
+			}
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Muted %s: %s]", executorName, player.Name, entry.Reason)))
+			return chat.Text(fmt.Sprintf("Muted %s: %s", player.Name, entry.Reason))
+		}
+	case "unmute":
+		{
+			id := GetArgument(args, 0)
+			if id == "" {
+				return chat.Text("§cPlease specify a player to unmute")
+			}
+			ok, unmuted := server.Players.RemoveMute(id)
+			if !ok {
+				return chat.Text(fmt.Sprintf("§c%s is not muted", id))
+			}
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Unmuted %s]", executorName, unmuted.Name)))
+			return chat.Text(fmt.Sprintf("Unmuted %s", unmuted.Name))
+		}
+	case "channel":
+		return server.ChatChannelCommand(executor, executorPlayer, args)
+	case "delete":
+		{
+			id, err := strconv.Atoi(GetArgument(args, 0))
+			if err != nil {
+				return chat.Text("§cPlease specify the id of a message")
+			}
+			ok, entry := chatHistory.Remove(id)
+			if !ok {
+				return chat.Text("§cThis message can't be deleted")
+			}
+			server.DeleteMessage(entry.Signature)
+			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Deleted a message from %s]", executorName, entry.Sender)))
+			return chat.Text(fmt.Sprintf("Deleted a message from %s", entry.Sender))
+		}
+	case "msg", "tell", "w":
+		{
+			targets := ParseTargets(executorPlayer, GetArgument(args, 0))
+			if len(targets) == 0 {
+				return chat.Text("§cNo player was found")
+			}
+			if len(args) < 2 {
+				return chat.Text("§cPlease specify a message")
+			}
+			message := strings.Join(args[1:], " ")
+			for _, target := range targets {
+				server.PrivateMessage(executor, target.UUID.String, message)
+			}
+			return chat.Message{}
+		}
+	case "r":
+		{
+			target, ok := replyTargets.Load(executor)
+			if !ok || (target != "console" && server.Players.Players[target.(string)] == nil) {
+				return chat.Text("§cThere is no one to reply to")
+			}
+			if len(args) == 0 {
+				return chat.Text("§cPlease specify a message")
+			}
+			server.PrivateMessage(executor, target.(string), strings.Join(args, " "))
+			return chat.Message{}
+		}
+	case "me":
+		{
+			if len(args) == 0 {
+				return chat.Text("§cPlease specify an action")
+			}
+			server.BroadcastChatTypeMessage("minecraft:emote_command", chat.Text(strings.Join(args, " ")), chat.Text(server.GetName(executor)))
+			return chat.Message{}
+		}
+	case "say":
+		{
+			if len(args) == 0 {
+				return chat.Text("§cPlease specify a message")
+			}
+			server.BroadcastChatTypeMessage("minecraft:say_command", chat.Text(strings.Join(args, " ")), chat.Text(server.GetName(executor)))
+			return chat.Message{}
+		}
+	case "tellraw":
+		{
+			targets := ParseTargets(executorPlayer, GetArgument(args, 0))
+			if len(targets) == 0 {
+				return chat.Text("§cNo player was found")
+			}
+			var message chat.Message
+			if err := message.UnmarshalJSON([]byte(strings.Join(args[1:], " "))); err != nil {
+				return chat.Text("§cInvalid chat component: " + err.Error())
+			}
+			for _, target := range targets {
+				server.Message(target.UUID.String, message)
+			}
+			return chat.Message{}
 		}
 	case "gamemode":
 		{
 			gamemode := GetArgument(args, 0)
 			id := GetArgument(args, 1)
-			var player *Player
 			if gamemode == "" {
 				return chat.Text("§cPlease specify a gamemode")
 			}
+			mode, ok := ParseGamemode(gamemode)
+			if !ok {
+				return chat.Text(fmt.Sprintf("§cUnknown gamemode: %s", gamemode))
+			}
+			gamemode = GamemodeName(int32(mode))
 			if id == "" {
-				if executor == "console" {
+				if executorPlayer == nil {
 					return chat.Text("§cThe gamemode command can only be used on players")
 				} else {
 					id = executor
 				}
 			}
-			var mode float32
-			switch gamemode {
-			case "survival":
-				mode = 0
-			case "creative":
-				mode = 1
-			case "adventure":
-				mode = 2
-			case "spectator":
-				mode = 3
-			}
-			if server.Players.PlayerNames[id] == "" {
-				if server.Players.Players[id].UUID.String != id {
-					return chat.Text("§cUnknown player")
-				} else {
-					player = server.Players.Players[id]
-				}
-			} else {
-				player = server.Players.Players[server.Players.PlayerNames[id]]
-			}
-			player.Connection.WritePacket(pk.Marshal(
-				packetid.ClientboundGameEvent,
-				pk.UnsignedByte(3),
-				pk.Float(mode),
-			))
-			server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Set %s's gamemode to %s]", executorName, player.Name, gamemode)))
-			if executor == player.UUID.String {
+			players := ParseTargets(executorPlayer, id)
+			if len(players) == 0 {
+				return chat.Text("§cUnknown player")
+			}
+			for _, player := range players {
+				player.SetGamemode(mode)
+				server.BroadcastMessageAdmin(executor, chat.Text(fmt.Sprintf("§7[%s: Set %s's gamemode to %s]", executorName, player.Name, gamemode)))
+			}
+			if len(players) > 1 {
+				return chat.Text(fmt.Sprintf("Set the gamemode of %d players to %s", len(players), gamemode))
+			}
+			if executor == players[0].UUID.String {
 				return chat.Text(fmt.Sprintf("Set own gamemode to %s", gamemode))
 			} else {
-				return chat.Text(fmt.Sprintf("Set %s's gamemode to %s", player.Name, gamemode))
+				return chat.Text(fmt.Sprintf("Set %s's gamemode to %s", players[0].Name, gamemode))
 			}
 		}
 	case "ram":
@@ -207,95 +690,7 @@ func (server *Server) Command(executor string, content string) chat.Message {
 		runtime.ReadMemStats(&m)
 		return chat.Text(fmt.Sprintf("Allocated: %v MiB, Total Allocated: %v MiB", bToMb(m.Alloc), bToMb(m.TotalAlloc)))
 	case "teleport", "tp":
-		{
-			switch len(args) {
-			case 1:
-				{
-					if executor == "console" {
-						return chat.Text("§cOnly players can be teleported")
-					}
-					is, target := ParseTarget(executorPlayer, args[0])
-					if !is {
-						return chat.Text("§cUnknown player at argument 0")
-					}
-					executorPlayer.Connection.WritePacket(pk.Marshal(packetid.ClientboundPlayerPosition,
-						pk.Double(target.Position[0]),        //x
-						pk.Double(target.Position[1]),        //y
-						pk.Double(target.Position[2]),        //z
-						pk.Float(executorPlayer.Rotation[0]), //yaw
-						pk.Float(executorPlayer.Rotation[1]), //pitch
-						pk.Byte(0),
-						pk.VarInt(server.NewTeleportID()),
-					))
-					return chat.Text(fmt.Sprintf("Teleported you to %s", target.Name))
-				}
-			case 2:
-				{
-					is, player := ParseTarget(executorPlayer, args[0])
-					if !is {
-						return chat.Text("§cUnknown player at argument 0")
-					}
-					is, target := ParseTarget(executorPlayer, args[1])
-					if !is {
-						return chat.Text("§cUnknown player at argument 1")
-					}
-					player.Connection.WritePacket(pk.Marshal(packetid.ClientboundPlayerPosition,
-						pk.Double(target.Position[0]), //x
-						pk.Double(target.Position[1]), //y
-						pk.Double(target.Position[2]), //z
-						pk.Float(player.Rotation[0]),  //yaw
-						pk.Float(player.Rotation[1]),  //pitch
-						pk.Byte(0),
-						pk.VarInt(server.NewTeleportID()),
-					))
-					return chat.Text(fmt.Sprintf("Teleported %s to %s", player.Name, target.Name))
-				}
-			case 3:
-				{
-					x, xerr := strconv.ParseFloat(args[0], 64)
-					y, yerr := strconv.ParseFloat(args[1], 64)
-					z, zerr := strconv.ParseFloat(args[2], 64)
-					if xerr != nil || yerr != nil || zerr != nil {
-						return chat.Text("§cPassed argument is not a float")
-					}
-					executorPlayer.Connection.WritePacket(pk.Marshal(packetid.ClientboundPlayerPosition,
-						pk.Double(x),                         //x
-						pk.Double(y),                         //y
-						pk.Double(z),                         //z
-						pk.Float(executorPlayer.Rotation[0]), //yaw
-						pk.Float(executorPlayer.Rotation[1]), //pitch
-						pk.Byte(0),
-						pk.VarInt(server.NewTeleportID()),
-					))
-					return chat.Text(fmt.Sprintf("Teleported you to %v %v %v", x, y, z))
-				}
-			case 4:
-				{
-					is, player := ParseTarget(executorPlayer, args[0])
-					if !is {
-						return chat.Text("§cUnknown player at argument 0")
-					}
-					x, xerr := strconv.ParseFloat(args[1], 64)
-					y, yerr := strconv.ParseFloat(args[2], 64)
-					z, zerr := strconv.ParseFloat(args[3], 64)
-					if xerr != nil || yerr != nil || zerr != nil {
-						return chat.Text("§cPassed argument is not a float")
-					}
-					player.Connection.WritePacket(pk.Marshal(packetid.ClientboundPlayerPosition,
-						pk.Double(x),                 //x
-						pk.Double(y),                 //y
-						pk.Double(z),                 //z
-						pk.Float(player.Rotation[0]), //yaw
-						pk.Float(player.Rotation[1]), //pitch
-						pk.Byte(0),
-						pk.VarInt(server.NewTeleportID()),
-					))
-					return chat.Text(fmt.Sprintf("Teleported %s to %v %v %v", player.Name, x, y, z))
-				}
-			default:
-				return chat.Text("§cInvalid amount of arguments")
-			}
-		}
+		return server.TeleportCommand(executorPlayer, args)
 	default:
 		return chat.Text(server.Config.Messages.UnknownCommand)
 	}
@@ -322,6 +717,48 @@ func ParseTarget(executor *Player, arg string) (bool, *Player) {
 	return false, nil
 }
 
+// returns the players matched by a selector (@a, @e, @p, @r, @s), name or uuid
+func ParseTargets(executor *Player, arg string) []*Player {
+	switch arg {
+	case "@a", "@e":
+		players := make([]*Player, 0, len(server.Players.Players))
+		for _, player := range server.Players.Players {
+			players = append(players, player)
+		}
+		return players
+	case "@r":
+		if len(server.Players.PlayerIDs) == 0 {
+			return nil
+		}
+		player := server.Players.Players[server.Players.PlayerIDs[rand.Intn(len(server.Players.PlayerIDs))]]
+		if player == nil {
+			return nil
+		}
+		return []*Player{player}
+	case "@p":
+		var origin [3]float64
+		if executor != nil {
+			origin = executor.Position
+		}
+		var nearest *Player
+		var distance float64
+		for _, player := range server.Players.Players {
+			dx, dy, dz := player.Position[0]-origin[0], player.Position[1]-origin[1], player.Position[2]-origin[2]
+			if d := dx*dx + dy*dy + dz*dz; nearest == nil || d < distance {
+				nearest, distance = player, d
+			}
+		}
+		if nearest == nil {
+			return nil
+		}
+		return []*Player{nearest}
+	}
+	if is, player := ParseTarget(executor, arg); is && player != nil {
+		return []*Player{player}
+	}
+	return nil
+}
+
 func bToMb(b uint64) uint64 {
 	return b / 1024 / 1024
 }
@@ -14,6 +14,7 @@ type Tablist struct {
 type Messages struct {
 	NotInWhitelist          string `yaml:"not_in_whitelist"`
 	Banned                  string `yaml:"banned"`
+	TempBanned              string `yaml:"temp_banned"`
 	ServerFull              string `yaml:"server_full"`
 	AlreadyPlaying          string `yaml:"already_playing"`
 	PlayerJoin              string `yaml:"player_join"`
@@ -25,6 +26,25 @@ type Messages struct {
 	ReloadComplete          string `yaml:"reload_complete"`
 	ServerClosed            string `yaml:"server_closed"`
 	OnlineMode              string `yaml:"online_mode"`
+	Throttled               string `yaml:"throttled"`
+	TooManyConnections      string `yaml:"too_many_connections"`
+	ServerBusy              string `yaml:"server_busy"`
+	TimedOut                string `yaml:"timed_out"`
+	ProxyRequired           string `yaml:"proxy_required"`
+	LoginQueryFailed        string `yaml:"login_query_failed"`
+	InvalidPublicKey        string `yaml:"invalid_public_key"`
+	ExpiredPublicKey        string `yaml:"expired_public_key"`
+	UnsignedChat            string `yaml:"unsigned_chat"`
+	ChatValidationFailed    string `yaml:"chat_validation_failed"`
+	OutOfOrderChat          string `yaml:"out_of_order_chat"`
+	TooManyPendingChats     string `yaml:"too_many_pending_chats"`
+	NoChatChannel           string `yaml:"no_chat_channel"`
+	TooManyChannels         string `yaml:"too_many_channels"`
+	Muted                   string `yaml:"muted"`
+	TempMuted               string `yaml:"temp_muted"`
+	ChatFiltered            string `yaml:"chat_filtered"`
+	ChatTooFast             string `yaml:"chat_too_fast"`
+	ChatRepeated            string `yaml:"chat_repeated"`
 }
 
 type Icon struct {
@@ -32,10 +52,38 @@ type Icon struct {
 	Enable bool   `yaml:"enable"`
 }
 
+type ChatFilter struct {
+	Pattern     string `yaml:"pattern"`
+	Regex       bool   `yaml:"regex"`
+	Action      string `yaml:"action"`
+	Replacement string `yaml:"replacement"`
+}
+
+type AntiSpam struct {
+	// messages allowed per 10 seconds
+	RateLimit   int `yaml:"rate_limit"`
+	RepeatLimit int `yaml:"repeat_limit"`
+}
+
+type ChatChannel struct {
+	Name        string  `yaml:"name"`
+	DisplayName string  `yaml:"display_name"`
+	Type        string  `yaml:"type"`
+	Radius      float64 `yaml:"radius"`
+	World       string  `yaml:"world"`
+	Permission  string  `yaml:"permission"`
+	AutoJoin    bool    `yaml:"auto_join"`
+}
+
 type Chat struct {
-	Format string `yaml:"format"`
-	Colors bool   `yaml:"colors"`
-	Enable bool   `yaml:"enable"`
+	Format         string        `yaml:"format"`
+	Colors         bool          `yaml:"colors"`
+	SecureChat     string        `yaml:"secure_chat"`
+	Filters        []ChatFilter  `yaml:"filters"`
+	AntiSpam       AntiSpam      `yaml:"anti_spam"`
+	Channels       []ChatChannel `yaml:"channels"`
+	DefaultChannel string        `yaml:"default_channel"`
+	Enable         bool          `yaml:"enable"`
 }
 
 type Whitelist struct {
@@ -43,74 +91,205 @@ type Whitelist struct {
 	Enable  bool `yaml:"enable"`
 }
 
+type RCON struct {
+	ServerIP   string `yaml:"server_ip"`
+	ServerPort int    `yaml:"server_port"`
+	Password   string `yaml:"password"`
+	RateLimit  int    `yaml:"rate_limit"`
+	// failed logins an ip may have before it is locked out
+	LoginAttempts int  `yaml:"login_attempts"`
+	Enable        bool `yaml:"enable"`
+}
+
+type PlayerSample struct {
+	Custom []string `yaml:"custom"`
+	Max    int      `yaml:"max"`
+	Hide   bool     `yaml:"hide"`
+}
+
+type ServerList struct {
+	Sample     PlayerSample `yaml:"sample"`
+	RateLimit  int          `yaml:"rate_limit"`
+	HideOnline bool         `yaml:"hide_online"`
+}
+
+type Throttle struct {
+	LoginInterval    int `yaml:"login_interval"`
+	MaxConnections   int `yaml:"max_connections_per_ip"`
+	MaxPendingLogins int `yaml:"max_pending_logins"`
+}
+
+type Forwarding struct {
+	Mode   string `yaml:"mode"`
+	Secret string `yaml:"secret"`
+	// the addresses BungeeCord forwarding is accepted from, it can't be verified otherwise
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type ProxyProtocol struct {
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	Enable         bool     `yaml:"enable"`
+}
+
+type Query struct {
+	ServerPort int  `yaml:"server_port"`
+	Enable     bool `yaml:"enable"`
+}
+
 type Config struct {
-	ServerName         string    `yaml:"server_name"`
-	ServerIP           string    `yaml:"server_ip"`
-	ServerPort         int       `yaml:"server_port"`
-	ViewDistance       int       `yaml:"view_distance"`
-	SimulationDistance int       `yaml:"simulation_distance"`
-	MOTD               string    `yaml:"motd"`
-	Icon               Icon      `yaml:"icon"`
-	Whitelist          Whitelist `yaml:"whitelist"`
-	Gamemode           string    `yaml:"gamemode"`
-	Hardcore           bool      `yaml:"hardcore"`
-	MaxPlayers         int       `yaml:"max_players"`
-	Online             bool      `yaml:"online_mode"`
-	Tablist            Tablist   `yaml:"tablist"`
-	Chat               Chat      `yaml:"chat"`
-	Messages           Messages  `yaml:"messages"`
+	ServerName           string        `yaml:"server_name"`
+	ServerIP             string        `yaml:"server_ip"`
+	ServerPort           int           `yaml:"server_port"`
+	ViewDistance         int           `yaml:"view_distance"`
+	SimulationDistance   int           `yaml:"simulation_distance"`
+	MOTD                 string        `yaml:"motd"`
+	MOTDs                []string      `yaml:"motds"`
+	VersionName          string        `yaml:"version_name"`
+	Icon                 Icon          `yaml:"icon"`
+	ServerList           ServerList    `yaml:"server_list"`
+	Whitelist            Whitelist     `yaml:"whitelist"`
+	Gamemode             string        `yaml:"gamemode"`
+	ForceGamemode        bool          `yaml:"force_gamemode"`
+	Hardcore             bool          `yaml:"hardcore"`
+	MaxPlayers           int           `yaml:"max_players"`
+	Online               bool          `yaml:"online_mode"`
+	Throttle             Throttle      `yaml:"throttle"`
+	Forwarding           Forwarding    `yaml:"forwarding"`
+	ProxyProtocol        ProxyProtocol `yaml:"proxy_protocol"`
+	CompressionThreshold int           `yaml:"network_compression_threshold"`
+	OPPermissionLevel    int           `yaml:"op_permission_level"`
+	Tablist              Tablist       `yaml:"tablist"`
+	Chat                 Chat          `yaml:"chat"`
+	RCON                 RCON          `yaml:"rcon"`
+	Query                Query         `yaml:"query"`
+	Messages             Messages      `yaml:"messages"`
+}
+
+// returns the config that is written to config.yml when there is none
+func DefaultConfig() *Config {
+	return &Config{
+		ServerName:  "Dynamite",
+		ServerIP:    "0.0.0.0",
+		ServerPort:  25565,
+		MOTD:        "A Dynamite Minecraft Server",
+		MOTDs:       []string{},
+		VersionName: "Dynamite %version%",
+		Whitelist: Whitelist{
+			Enforce: false,
+			Enable:  false,
+		},
+		Gamemode:           "survival",
+		Hardcore:           false,
+		MaxPlayers:         200,
+		Online:             true,
+		OPPermissionLevel:  4,
+		ViewDistance:       10,
+		SimulationDistance: 10,
+		Throttle: Throttle{
+			LoginInterval:    4000,
+			MaxConnections:   3,
+			MaxPendingLogins: 50,
+		},
+		CompressionThreshold: 256,
+		Forwarding: Forwarding{
+			Mode:           FORWARDING_NONE,
+			Secret:         "",
+			TrustedProxies: []string{"127.0.0.1", "::1"},
+		},
+		ProxyProtocol: ProxyProtocol{
+			TrustedProxies: []string{"127.0.0.1", "::1"},
+			Enable:         false,
+		},
+		Messages: Messages{
+			NotInWhitelist:          "You are not whitelisted.",
+			Banned:                  "You are banned from this server.\nReason: %reason%",
+			TempBanned:              "You are banned from this server.\nReason: %reason%\nYour ban will be removed on %expires%",
+			ServerFull:              "The server is full.",
+			AlreadyPlaying:          "You are already playing on this server with a different client.",
+			PlayerJoin:              "§e%player% has joined the game",
+			PlayerLeave:             "§e%player% has left the game",
+			UnknownCommand:          "§cUnknown command. Please use '/help' for a list of commands.",
+			ProtocolNew:             "Your protocol is too new!",
+			ProtocolOld:             "Your protocol is too old!",
+			InsufficientPermissions: "§cYou aren't permitted to use this command.",
+			ReloadComplete:          "§aReload complete.",
+			ServerClosed:            "Server closed.",
+			OnlineMode:              "The server is in online mode.",
+			Throttled:               "Connection throttled! Please wait before reconnecting.",
+			TooManyConnections:      "Too many connections from your IP address.",
+			ServerBusy:              "The server is busy, please try again later.",
+			TimedOut:                "Timed out",
+			ProxyRequired:           "This server requires you to connect through its proxy.",
+			LoginQueryFailed:        "Your client did not answer the server's login requests.",
+			InvalidPublicKey:        "Invalid signature for profile public key.\nTry restarting your game.",
+			ExpiredPublicKey:        "Expired profile public key. Check that your system time is synchronized, and try restarting your game.",
+			UnsignedChat:            "Received chat packet with missing or invalid signature.",
+			ChatValidationFailed:    "Chat message validation failure",
+			OutOfOrderChat:          "Out-of-order chat packet received. Did your system time change?",
+			TooManyPendingChats:     "Too many unacknowledged chat messages",
+			NoChatChannel:           "§cYou are not talking in a chat channel, use /channel focus <channel> to pick one",
+			TooManyChannels:         "Too many plugin channels registered",
+			Muted:                   "§cYou are muted.\nReason: %reason%",
+			TempMuted:               "§cYou are muted until %expires%.\nReason: %reason%",
+			ChatFiltered:            "§cYour message was blocked by the chat filter.",
+			ChatTooFast:             "§cYou are sending messages too quickly.",
+			ChatRepeated:            "§cPlease don't repeat the same message.",
+		},
+		Icon: Icon{
+			Path:   "server-icon.png",
+			Enable: false,
+		},
+		ServerList: ServerList{
+			Sample: PlayerSample{
+				Custom: []string{},
+				Max:    12,
+				Hide:   false,
+			},
+			RateLimit:  30,
+			HideOnline: false,
+		},
+		Tablist: Tablist{
+			Header: []string{},
+			Footer: []string{},
+		},
+		Chat: Chat{
+			Colors:     false,
+			Format:     "<%player_prefix%%player%> %message%",
+			SecureChat: SECURE_CHAT_ENFORCE,
+			Filters:    []ChatFilter{},
+			AntiSpam: AntiSpam{
+				RateLimit:   10,
+				RepeatLimit: 3,
+			},
+			Channels: []ChatChannel{
+				{Name: "global", DisplayName: "G", Type: CHAT_CHANNEL_GLOBAL, AutoJoin: true},
+				{Name: "local", DisplayName: "L", Type: CHAT_CHANNEL_LOCAL, Radius: 100, AutoJoin: true},
+				{Name: "staff", DisplayName: "Staff", Type: CHAT_CHANNEL_GLOBAL, Permission: "server.chat.staff", AutoJoin: true},
+			},
+			DefaultChannel: "global",
+			Enable:         true,
+		},
+		RCON: RCON{
+			ServerIP:      "0.0.0.0",
+			ServerPort:    25575,
+			Password:      "",
+			RateLimit:     10,
+			LoginAttempts: 5,
+			Enable:        false,
+		},
+		Query: Query{
+			ServerPort: 25565,
+			Enable:     false,
+		},
+	}
 }
 
 func LoadConfig() *Config {
-	config := &Config{}
+	config := DefaultConfig()
 
 	file, err := os.Open("config.yml")
 	if err != nil {
 		file.Close()
-		config = &Config{
-			ServerName: "Dynamite",
-			ServerIP:   "0.0.0.0",
-			ServerPort: 25565,
-			MOTD:       "A Dynamite Minecraft Server",
-			Whitelist: Whitelist{
-				Enforce: false,
-				Enable:  false,
-			},
-			Gamemode:           "survival",
-			Hardcore:           false,
-			MaxPlayers:         200,
-			Online:             true,
-			ViewDistance:       10,
-			SimulationDistance: 10,
-			Messages: Messages{
-				NotInWhitelist:          "You are not whitelisted.",
-				Banned:                  "You are banned from this server.",
-				ServerFull:              "The server is full.",
-				AlreadyPlaying:          "You are already playing on this server with a different client.",
-				PlayerJoin:              "§e%player% has joined the game",
-				PlayerLeave:             "§e%player% has left the game",
-				UnknownCommand:          "§cUnknown command. Please use '/help' for a list of commands.",
-				ProtocolNew:             "Your protocol is too new!",
-				ProtocolOld:             "Your protocol is too old!",
-				InsufficientPermissions: "§cYou aren't permitted to use this command.",
-				ReloadComplete:          "§aReload complete.",
-				ServerClosed:            "Server closed.",
-				OnlineMode:              "The server is in online mode.",
-			},
-			Icon: Icon{
-				Path:   "server-icon.png",
-				Enable: false,
-			},
-			Tablist: Tablist{
-				Header: []string{},
-				Footer: []string{},
-			},
-			Chat: Chat{
-				Colors: false,
-				Format: "<%player_prefix%%player%> %message%",
-				Enable: true,
-			},
-		}
 		file, _ := os.Create("config.yml")
 		e := yaml.NewEncoder(file)
 		e.Encode(&config)
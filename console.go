@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+const (
+	ConsolePrompt      = "> "
+	ConsoleHistoryFile = "console_history.txt"
+	ConsoleHistorySize = 500
+)
+
+// Console is an interactive command line on stdin that keeps its prompt below the log output
+type Console struct {
+	sync.Mutex
+	line         []rune
+	pos          int
+	history      []string
+	historyIndex int
+	lastTab      bool
+	state        *term.State
+}
+
+func (console *Console) Write(p []byte) (int, error) {
+	console.Lock()
+	defer console.Unlock()
+	if console.state == nil {
+		return os.Stdout.Write(p)
+	}
+	os.Stdout.WriteString("\r\033[K")
+	os.Stdout.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n")))
+	console.redraw()
+	return len(p), nil
+}
+
+// redraws the prompt and the line being edited, must be called with the lock held
+func (console *Console) redraw() {
+	os.Stdout.WriteString("\r\033[K" + ConsolePrompt + string(console.line))
+	if back := len(console.line) - console.pos; back > 0 {
+		os.Stdout.WriteString("\033[" + strconv.Itoa(back) + "D")
+	}
+}
+
+// restores the terminal, must be called before the process exits
+func (console *Console) Restore() {
+	console.Lock()
+	defer console.Unlock()
+	if console.state != nil {
+		term.Restore(int(os.Stdin.Fd()), console.state)
+		console.state = nil
+		os.Stdout.WriteString("\r\n")
+	}
+}
+
+func (console *Console) loadHistory() {
+	data, err := os.ReadFile(ConsoleHistoryFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			console.history = append(console.history, line)
+		}
+	}
+	if len(console.history) > ConsoleHistorySize {
+		console.history = console.history[len(console.history)-ConsoleHistorySize:]
+		os.WriteFile(ConsoleHistoryFile, []byte(strings.Join(console.history, "\n")+"\n"), 0755)
+	}
+}
+
+func (console *Console) addHistory(line string) {
+	if len(console.history) > 0 && console.history[len(console.history)-1] == line {
+		return
+	}
+	console.history = append(console.history, line)
+	file, err := os.OpenFile(ConsoleHistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(line + "\n")
+}
+
+func (console *Console) execute(command string) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return
+	}
+	console.addHistory(command)
+	if message := server.Command("console", command); message.String() != "" {
+		server.Logger.Print("%v", message)
+	}
+}
+
+func (console *Console) complete() {
+	line := string(console.line[:console.pos])
+	start, suggestions := server.Suggest("console", line)
+	if len(suggestions) == 0 {
+		return
+	}
+	// the common prefix of the suggestions, compared by rune so multi-byte characters aren't cut
+	word := []rune(suggestions[0])
+	for _, suggestion := range suggestions[1:] {
+		s := []rune(suggestion)
+		n := 0
+		for n < len(word) && n < len(s) && unicode.ToLower(word[n]) == unicode.ToLower(s[n]) {
+			n++
+		}
+		word = word[:n]
+	}
+	if len(suggestions) == 1 {
+		word = append(word, ' ')
+	}
+	if len(suggestions) > 1 && len(word) <= len([]rune(line[start:])) {
+		if console.lastTab {
+			os.Stdout.WriteString("\r\033[K" + strings.Join(suggestions, "  ") + "\r\n")
+		}
+		return
+	}
+	head := append([]rune(line[:start]), word...)
+	console.line = append(head, console.line[console.pos:]...)
+	console.pos = len(head)
+}
+
+func (console *Console) setLine(line string) {
+	console.line = []rune(line)
+	console.pos = len(console.line)
+}
+
+// reads and handles one key, returns false when stdin is closed
+func (console *Console) handleKey(reader *bufio.Reader) bool {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return false
+	}
+	console.Lock()
+	defer console.Unlock()
+	tab := false
+	switch r {
+	case '\r', '\n':
+		line := string(console.line)
+		console.line, console.pos = nil, 0
+		console.historyIndex = len(console.history)
+		os.Stdout.WriteString("\r\033[K" + ConsolePrompt + line + "\r\n")
+		console.redraw()
+		console.Unlock()
+		console.execute(line)
+		console.Lock()
+		return true
+	case 3: // ctrl+c
+		console.Unlock()
+		server.Logger.Print("%v", server.Command("console", "stop"))
+		console.Lock()
+	case 4: // ctrl+d
+		if len(console.line) == 0 {
+			return false
+		}
+	case 127, 8: // backspace
+		if console.pos > 0 {
+			console.line = append(console.line[:console.pos-1], console.line[console.pos:]...)
+			console.pos--
+		}
+	case '\t':
+		console.complete()
+		tab = true
+	case 1: // ctrl+a
+		console.pos = 0
+	case 5: // ctrl+e
+		console.pos = len(console.line)
+	case 21: // ctrl+u
+		console.line, console.pos = console.line[console.pos:], 0
+	case 23: // ctrl+w
+		i := console.pos
+		for i > 0 && console.line[i-1] == ' ' {
+			i--
+		}
+		for i > 0 && console.line[i-1] != ' ' {
+			i--
+		}
+		console.line = append(console.line[:i], console.line[console.pos:]...)
+		console.pos = i
+	case 27: // escape sequences
+		if b, _ := reader.ReadByte(); b != '[' && b != 'O' {
+			break
+		}
+		b, _ := reader.ReadByte()
+		switch b {
+		case 'A': // up
+			if console.historyIndex > 0 {
+				console.historyIndex--
+				console.setLine(console.history[console.historyIndex])
+			}
+		case 'B': // down
+			if console.historyIndex < len(console.history)-1 {
+				console.historyIndex++
+				console.setLine(console.history[console.historyIndex])
+			} else {
+				console.historyIndex = len(console.history)
+				console.setLine("")
+			}
+		case 'C': // right
+			if console.pos < len(console.line) {
+				console.pos++
+			}
+		case 'D': // left
+			if console.pos > 0 {
+				console.pos--
+			}
+		case 'H':
+			console.pos = 0
+		case 'F':
+			console.pos = len(console.line)
+		case '3': // delete
+			reader.ReadByte()
+			if console.pos < len(console.line) {
+				console.line = append(console.line[:console.pos], console.line[console.pos+1:]...)
+			}
+		}
+	default:
+		if unicode.IsPrint(r) {
+			console.line = append(console.line[:console.pos], append([]rune{r}, console.line[console.pos:]...)...)
+			console.pos++
+		}
+	}
+	console.lastTab = tab
+	console.redraw()
+	return true
+}
+
+func (console *Console) Start() {
+	console.loadHistory()
+	console.historyIndex = len(console.history)
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			console.execute(scanner.Text())
+		}
+		return
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		server.Logger.Error("Failed to start the interactive console: %s", err)
+		return
+	}
+	console.Lock()
+	console.state = state
+	console.redraw()
+	console.Unlock()
+	server.Logger.Out = console
+	reader := bufio.NewReader(os.Stdin)
+	for console.handleKey(reader) {
+	}
+	console.Restore()
+}
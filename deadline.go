@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	// how long a single write may block before the connection is considered dead
+	WriteTimeout = 10 * time.Second
+	// how long the disconnect packet of a kick may take to write
+	KickTimeout = 2 * time.Second
+)
+
+// deadlineConn sets a fresh write deadline before every write, so a client that stops reading
+// can't block the goroutine writing to it forever
+type deadlineConn struct {
+	net.Conn
+}
+
+func (conn *deadlineConn) Write(b []byte) (int, error) {
+	conn.Conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return conn.Conn.Write(b)
+}
@@ -20,10 +20,10 @@ func OnPlayerJoin(params ...interface{}) {
 	player := params[0].(*Player)
 	connection := params[1].(net.Conn)
 	header, footer := server.Playerlist.GetTexts(player)
-	connection.WritePacket(pk.Marshal(0x17, pk.Identifier("minecraft:brand"), pk.String("Dynamite")))
-	connection.WritePacket(pk.Marshal(packetid.ClientboundTabList, chat.Text(header), chat.Text(footer)))
+	player.SendChannels()
+	connection.WritePacket(pk.Marshal(packetid.ClientboundTabList, header, footer))
 	fields := []pk.FieldEncoder{
-		chat.Text(server.Config.MOTD),
+		server.GetMOTD(PROTOCOL_1_19_4),
 		pk.Boolean(false),
 	}
 	if server.Config.Icon.Enable {
@@ -33,7 +33,7 @@ func OnPlayerJoin(params ...interface{}) {
 			fields = append(fields, pk.ByteArray(data))
 		}
 	}
-	fields = append(fields, pk.Boolean(true))
+	fields = append(fields, pk.Boolean(EnforcesSecureChat()))
 	connection.WritePacket(pk.Marshal(packetid.ClientboundServerData, fields...))
 	connection.WritePacket(pk.Marshal(packetid.ClientboundCommands, CommandGraph{player.UUID.String}))
 
@@ -48,31 +48,15 @@ func OnPlayerJoin(params ...interface{}) {
 
 	group, prefix, suffix := server.GetGroup(player.UUID.String)
 
-	server.BroadcastMessage(chat.Text(ParsePlaceholders(server.Config.Messages.PlayerJoin, Placeholders{PlayerName: player.Name, PlayerPrefix: prefix, PlayerSuffix: suffix, PlayerGroup: group})))
+	server.BroadcastMessage(FormatPlaceholders(server.Config.Messages.PlayerJoin, Placeholders{PlayerName: player.Name, PlayerPrefix: prefix, PlayerSuffix: suffix, PlayerGroup: group}, nil))
 	server.Playerlist.AddPlayer(player)
-	server.BroadcastPacketExcept(pk.Marshal(packetid.ClientboundAddPlayer,
-		pk.VarInt(player.EntityID),
-		player.UUID.Binary,
-		pk.Double(player.Position[0]),
-		pk.Double(player.Position[1]),
-		pk.Double(player.Position[2]),
-		pk.Angle(player.Rotation[0]),
-		pk.Angle(player.Rotation[1]),
-	), player.UUID.String)
+	server.BroadcastPacketExcept(player.SpawnPacket(), player.UUID.String)
 	server.Players.Lock()
 	for _, p := range server.Players.Players {
 		if p.UUID == player.UUID {
 			continue
 		}
-		connection.WritePacket(pk.Marshal(packetid.ClientboundAddPlayer,
-			pk.VarInt(p.EntityID),
-			p.UUID.Binary,
-			pk.Double(p.Position[0]),
-			pk.Double(p.Position[1]),
-			pk.Double(p.Position[2]),
-			pk.Angle(p.Rotation[0]),
-			pk.Angle(p.Rotation[1]),
-		))
+		connection.WritePacket(p.SpawnPacket())
 	}
 	server.Players.Unlock()
 }
@@ -81,6 +65,7 @@ func OnPlayerLeave(params ...interface{}) {
 	player := params[0].(*Player)
 	delete(server.Players.Players, player.UUID.String)
 	delete(server.Players.PlayerNames, player.Name)
+	InvalidateStatusCache()
 	max := fmt.Sprint(server.Config.MaxPlayers)
 	group, prefix, suffix := server.GetGroup(player.UUID.String)
 	message := server.Config.Messages.PlayerLeave
@@ -92,7 +77,7 @@ func OnPlayerLeave(params ...interface{}) {
 		playerContainer.Refresh()
 	}
 	server.Playerlist.RemovePlayer(player)
-	server.BroadcastMessage(chat.Text(ParsePlaceholders(message, Placeholders{PlayerName: player.Name, PlayerPrefix: prefix, PlayerSuffix: suffix, PlayerGroup: group})))
+	server.BroadcastMessage(FormatPlaceholders(message, Placeholders{PlayerName: player.Name, PlayerPrefix: prefix, PlayerSuffix: suffix, PlayerGroup: group}, nil))
 }
 
 func OnPlayerChatMessage(params ...interface{}) {
@@ -103,13 +88,29 @@ func OnPlayerChatMessage(params ...interface{}) {
 	if !server.HasPermissions(player.UUID.String, []string{"server.chat"}) {
 		return
 	}
-	packet := params[1].(pk.Packet)
-	server.BroadcastPlayerMessage(player, packet)
+	message := params[1].(*ChatMessage)
+	message.Channel = player.ChatChannel()
+	if message.Channel == "" && len(server.Config.Chat.Channels) > 0 {
+		server.Message(player.UUID.String, chat.Text(server.Config.Messages.NoChatChannel))
+		return
+	}
+	if ok, reason := server.ModerateChat(player, message); !ok {
+		server.Message(player.UUID.String, chat.Text(reason))
+		return
+	}
+	// listeners can change the content or channel of the message or cancel it
+	server.Events.Emit("PlayerChatFilter", player, message)
+	if message.Cancelled {
+		return
+	}
+	server.BroadcastPlayerMessage(player, message)
 }
 
 func OnPlayerCommand(params ...interface{}) {
 	player := params[0].(*Player)
 	command := params[1].(pk.String)
 	server.BroadcastMessageAdmin(player.UUID.String, chat.Text(fmt.Sprintf("Player %s (%s) executed command %s", player.Name, player.UUID.String, command)))
-	server.Message(player.UUID.String, server.Command(player.UUID.String, fmt.Sprint(command)))
+	if message := server.Command(player.UUID.String, fmt.Sprint(command)); message.String() != "" {
+		server.Message(player.UUID.String, message)
+	}
 }
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Tnze/go-mc/chat"
+)
+
+const (
+	TAG_COLOR         = "color"
+	TAG_BOLD          = "bold"
+	TAG_ITALIC        = "italic"
+	TAG_UNDERLINED    = "underlined"
+	TAG_STRIKETHROUGH = "strikethrough"
+	TAG_OBFUSCATED    = "obfuscated"
+	TAG_GRADIENT      = "gradient"
+	TAG_HOVER         = "hover"
+	TAG_CLICK         = "click"
+	TAG_RESET         = "reset"
+	TAG_NEWLINE       = "newline"
+)
+
+var tagColors = []string{
+	chat.Black, chat.DarkBlue, chat.DarkGreen, chat.DarkAqua, chat.DarkRed, chat.DarkPurple, chat.Gold, chat.Gray,
+	chat.DarkGray, chat.Blue, chat.Green, chat.Aqua, chat.Red, chat.LightPurple, chat.Yellow, chat.White,
+}
+
+var tagAliases = map[string]string{
+	"c":      TAG_COLOR,
+	"colour": TAG_COLOR,
+	"b":      TAG_BOLD,
+	"i":      TAG_ITALIC,
+	"em":     TAG_ITALIC,
+	"u":      TAG_UNDERLINED,
+	"st":     TAG_STRIKETHROUGH,
+	"obf":    TAG_OBFUSCATED,
+	"br":     TAG_NEWLINE,
+}
+
+// the tags server.chat.colors allows players to use
+var colorTags = []string{TAG_COLOR, TAG_BOLD, TAG_ITALIC, TAG_UNDERLINED, TAG_STRIKETHROUGH, TAG_OBFUSCATED, TAG_GRADIENT, TAG_RESET}
+
+// TagInput is text inserted into a formatted string after its tags are parsed, so it can't open tags of the string,
+// Allowed reports which tags the text may use itself and is nil if it may not use any
+type TagInput struct {
+	Text    string
+	Allowed func(tag string) bool
+}
+
+type tagGradient struct {
+	colors [][3]float64
+}
+
+type tagStyle struct {
+	color         string
+	gradient      *tagGradient
+	bold          bool
+	italic        bool
+	underlined    bool
+	strikethrough bool
+	obfuscated    bool
+	hover         *chat.HoverEvent
+	click         *chat.ClickEvent
+}
+
+type tagSegment struct {
+	text  string
+	style tagStyle
+}
+
+type openTag struct {
+	name string
+	// the style before the tag was opened
+	style tagStyle
+}
+
+type tagParser struct {
+	segments []tagSegment
+	stack    []openTag
+	style    tagStyle
+	inputs   map[string]TagInput
+	allowed  func(tag string) bool
+}
+
+// parses MiniMessage style tags like <red>, <bold>, <gradient:#ff0000:#0000ff>, <hover:show_text:'text'>
+// and <click:suggest_command:'/command'> into a chat component, unknown tags are kept as text and \< escapes a tag
+func FormatMessage(str string, inputs map[string]TagInput) chat.Message {
+	parser := &tagParser{inputs: inputs}
+	parser.parse(str)
+	return parser.message()
+}
+
+// formats a string of the config, the player name and message are inserted after the tags are parsed,
+// the message may use the tags allowed reports
+func FormatPlaceholders(str string, placeholders Placeholders, allowed func(tag string) bool) chat.Message {
+	inputs := map[string]TagInput{
+		"%player%":  {Text: placeholders.PlayerName},
+		"%message%": {Text: placeholders.Message, Allowed: allowed},
+	}
+	placeholders.PlayerName, placeholders.Message = "%player%", "%message%"
+	return FormatMessage(ParsePlaceholders(str, placeholders), inputs)
+}
+
+// returns which tags a player may use in chat, every tag needs server.chat.tags.<tag> and server.chat.colors allows the styling tags
+func (server Server) AllowedTags(id string) func(tag string) bool {
+	if !server.Config.Chat.Colors {
+		return nil
+	}
+	colors := server.HasPermissions(id, []string{"server.chat.colors"})
+	return func(tag string) bool {
+		if colors && contains(colorTags, tag) {
+			return true
+		}
+		return server.HasPermissions(id, []string{"server.chat.tags." + tag})
+	}
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func (parser *tagParser) parse(str string) {
+	var text strings.Builder
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			{
+				if i+1 < len(str) && (str[i+1] == '<' || str[i+1] == '\\') {
+					i++
+				}
+				text.WriteByte(str[i])
+			}
+		case '<':
+			{
+				ok, content := scanTag(str[i:])
+				if ok && parser.applyTag(content, &text) {
+					i += len(content) + 1
+				} else {
+					text.WriteByte('<')
+				}
+			}
+		default:
+			text.WriteByte(str[i])
+		}
+	}
+	parser.flush(&text)
+}
+
+// returns the content of the tag str starts with
+func scanTag(str string) (bool, string) {
+	var quote byte
+	for i := 1; i < len(str); i++ {
+		switch {
+		case quote != 0 && str[i] == '\\':
+			i++
+		case quote != 0:
+			if str[i] == quote {
+				quote = 0
+			}
+		case str[i] == '\'' || str[i] == '"':
+			quote = str[i]
+		case str[i] == '<' || str[i] == '\n':
+			return false, ""
+		case str[i] == '>':
+			return i > 1, str[1:i]
+		}
+	}
+	return false, ""
+}
+
+// splits the arguments of a tag on colons outside of quotes
+func splitTagArgs(content string) []string {
+	var (
+		args  []string
+		arg   strings.Builder
+		quote byte
+	)
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0 && c == '\\' && i+1 < len(content):
+			i++
+			arg.WriteByte(content[i])
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ':':
+			args = append(args, arg.String())
+			arg.Reset()
+		default:
+			arg.WriteByte(c)
+		}
+	}
+	return append(args, arg.String())
+}
+
+// returns the name of the tag or "" if it's unknown, colors are color tags
+func tagName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := tagAliases[name]; ok {
+		return alias
+	}
+	switch name {
+	case TAG_COLOR, TAG_BOLD, TAG_ITALIC, TAG_UNDERLINED, TAG_STRIKETHROUGH, TAG_OBFUSCATED, TAG_GRADIENT, TAG_HOVER, TAG_CLICK, TAG_RESET, TAG_NEWLINE:
+		return name
+	}
+	if ok, _ := parseTagColor(name); ok {
+		return TAG_COLOR
+	}
+	return ""
+}
+
+// parses a color name or a #rrggbb hex color
+func parseTagColor(color string) (bool, [3]float64) {
+	color = strings.ToLower(color)
+	if len(color) == 7 && color[0] == '#' {
+		value, err := strconv.ParseUint(color[1:], 16, 32)
+		if err != nil {
+			return false, [3]float64{}
+		}
+		return true, [3]float64{float64(value >> 16 & 0xff), float64(value >> 8 & 0xff), float64(value & 0xff)}
+	}
+	if color == chat.Gold {
+		return true, [3]float64{255, 170, 0}
+	}
+	for i, name := range tagColors {
+		if name == color {
+			// the rgb values of the legacy color codes
+			bright := float64(i>>3) * 85
+			return true, [3]float64{float64(i>>2&1)*170 + bright, float64(i>>1&1)*170 + bright, float64(i&1)*170 + bright}
+		}
+	}
+	return false, [3]float64{}
+}
+
+// applies a tag to the style, returns false if it's invalid or not allowed so it's kept as text
+func (parser *tagParser) applyTag(content string, text *strings.Builder) bool {
+	if strings.HasPrefix(content, "/") {
+		name := tagName(splitTagArgs(content[1:])[0])
+		for i := len(parser.stack) - 1; i >= 0; i-- {
+			if parser.stack[i].name == name {
+				parser.flush(text)
+				parser.style = parser.stack[i].style
+				parser.stack = parser.stack[:i]
+				return true
+			}
+		}
+		return false
+	}
+	args := splitTagArgs(content)
+	name := tagName(args[0])
+	if name == "" || (parser.allowed != nil && !parser.allowed(name)) {
+		return false
+	}
+	style := parser.style
+	switch name {
+	case TAG_COLOR:
+		{
+			// <red> and <#ff0000> are colors themselves, <color:red> has it as an argument
+			color := strings.ToLower(args[0])
+			if color == TAG_COLOR || tagAliases[color] == TAG_COLOR {
+				if len(args) < 2 {
+					return false
+				}
+				color = strings.ToLower(args[1])
+			}
+			if ok, _ := parseTagColor(color); !ok {
+				return false
+			}
+			style.color = color
+			style.gradient = nil
+		}
+	case TAG_BOLD:
+		style.bold = true
+	case TAG_ITALIC:
+		style.italic = true
+	case TAG_UNDERLINED:
+		style.underlined = true
+	case TAG_STRIKETHROUGH:
+		style.strikethrough = true
+	case TAG_OBFUSCATED:
+		style.obfuscated = true
+	case TAG_GRADIENT:
+		{
+			gradient := &tagGradient{}
+			for _, arg := range args[1:] {
+				ok, color := parseTagColor(arg)
+				if !ok {
+					return false
+				}
+				gradient.colors = append(gradient.colors, color)
+			}
+			if len(gradient.colors) == 0 {
+				gradient.colors = [][3]float64{{255, 255, 255}, {0, 0, 0}}
+			}
+			if len(gradient.colors) < 2 {
+				return false
+			}
+			style.gradient = gradient
+			style.color = ""
+		}
+	case TAG_HOVER:
+		{
+			if len(args) < 3 || strings.ToLower(args[1]) != "show_text" {
+				return false
+			}
+			hover := &tagParser{inputs: parser.inputs, allowed: parser.allowed}
+			hover.parse(strings.Join(args[2:], ":"))
+			style.hover = ShowText(hover.message())
+		}
+	case TAG_CLICK:
+		{
+			if len(args) < 3 {
+				return false
+			}
+			value := parser.insertInputs(strings.Join(args[2:], ":"))
+			switch strings.ToLower(args[1]) {
+			case "run_command":
+				style.click = chat.RunCommand(value)
+			case "suggest_command":
+				style.click = chat.SuggestCommand(value)
+			case "open_url":
+				style.click = chat.OpenURL(value)
+			case "copy_to_clipboard":
+				style.click = chat.CopyToClipboard(value)
+			default:
+				return false
+			}
+		}
+	case TAG_RESET:
+		{
+			parser.flush(text)
+			parser.style = tagStyle{}
+			parser.stack = nil
+			return true
+		}
+	case TAG_NEWLINE:
+		{
+			text.WriteByte('\n')
+			return true
+		}
+	}
+	parser.flush(text)
+	parser.stack = append(parser.stack, openTag{name: name, style: parser.style})
+	parser.style = style
+	return true
+}
+
+// replaces the inputs in a tag argument with their text
+func (parser *tagParser) insertInputs(str string) string {
+	for key, input := range parser.inputs {
+		str = strings.ReplaceAll(str, key, input.Text)
+	}
+	return str
+}
+
+// adds the text with the current style, inputs in it are parsed on their own
+func (parser *tagParser) flush(text *strings.Builder) {
+	str := text.String()
+	text.Reset()
+	for str != "" {
+		index, key := -1, ""
+		for k := range parser.inputs {
+			if i := strings.Index(str, k); i != -1 && (index == -1 || i < index) {
+				index, key = i, k
+			}
+		}
+		if index == -1 {
+			parser.add(str)
+			return
+		}
+		parser.add(str[:index])
+		input := parser.inputs[key]
+		if input.Allowed == nil {
+			parser.add(input.Text)
+		} else {
+			// the input keeps the style around it but can't close its tags
+			child := &tagParser{segments: parser.segments, style: parser.style, allowed: input.Allowed}
+			child.parse(input.Text)
+			parser.segments = child.segments
+		}
+		str = str[index+len(key):]
+	}
+}
+
+func (parser *tagParser) add(text string) {
+	if text != "" {
+		parser.segments = append(parser.segments, tagSegment{text: text, style: parser.style})
+	}
+}
+
+// builds the component, gradients are split into a component per character
+func (parser *tagParser) message() chat.Message {
+	if len(parser.segments) == 0 {
+		return chat.Text("")
+	}
+	if len(parser.segments) == 1 && parser.segments[0].style == (tagStyle{}) {
+		return chat.Text(parser.segments[0].text)
+	}
+	lengths := make(map[*tagGradient]int)
+	for _, segment := range parser.segments {
+		if segment.style.gradient != nil {
+			lengths[segment.style.gradient] += len([]rune(segment.text))
+		}
+	}
+	positions := make(map[*tagGradient]int)
+	message := chat.Message{}
+	for _, segment := range parser.segments {
+		gradient := segment.style.gradient
+		if gradient == nil {
+			message.Extra = append(message.Extra, segment.component(segment.text, segment.style.color))
+			continue
+		}
+		for _, r := range segment.text {
+			color := gradient.at(positions[gradient], lengths[gradient])
+			positions[gradient]++
+			message.Extra = append(message.Extra, segment.component(string(r), color))
+		}
+	}
+	return message
+}
+
+// returns the hex color of character i of length characters
+func (gradient *tagGradient) at(i int, length int) string {
+	t := 0.0
+	if length > 1 {
+		t = float64(i) / float64(length-1) * float64(len(gradient.colors)-1)
+	}
+	index := int(math.Min(t, float64(len(gradient.colors)-2)))
+	t -= float64(index)
+	from, to := gradient.colors[index], gradient.colors[index+1]
+	var rgb [3]int
+	for c := range rgb {
+		rgb[c] = int(math.Round(from[c] + (to[c]-from[c])*t))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+// like chat.ShowText but also sets the contents, clients read those before the legacy value and go-mc leaves them null
+func ShowText(text chat.Message) *chat.HoverEvent {
+	hover := chat.ShowText(text)
+	hover.Contents, _ = text.MarshalJSON()
+	return hover
+}
+
+func (segment tagSegment) component(text string, color string) chat.Message {
+	return chat.Message{
+		Text:          text,
+		Color:         color,
+		Bold:          segment.style.bold,
+		Italic:        segment.style.italic,
+		UnderLined:    segment.style.underlined,
+		StrikeThrough: segment.style.strikethrough,
+		Obfuscated:    segment.style.obfuscated,
+		HoverEvent:    segment.style.hover,
+		ClickEvent:    segment.style.click,
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Tnze/go-mc/chat"
+)
+
+// returns the json of a component with < and > unescaped so the expected values stay readable
+func componentJSON(message chat.Message) string {
+	data, _ := message.MarshalJSON()
+	return strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(string(data))
+}
+
+func TestFormatMessage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "hello", want: `{"text":"hello"}`},
+		{in: "<red>hi", want: `{"text":"","extra":[{"text":"hi","color":"red"}]}`},
+		{in: "<color:gold>g", want: `{"text":"","extra":[{"text":"g","color":"gold"}]}`},
+		{in: "<#ff0000>hi</#ff0000> there", want: `{"text":"","extra":[{"text":"hi","color":"#ff0000"},{"text":" there"}]}`},
+		{in: "<b>bold <i>both</i> bold</b> plain", want: `{"text":"","extra":[{"text":"bold ","bold":true},{"text":"both","bold":true,"italic":true},{"text":" bold","bold":true},{"text":" plain"}]}`},
+		{in: "<red>a<reset>b", want: `{"text":"","extra":[{"text":"a","color":"red"},{"text":"b"}]}`},
+		{in: "a<br>b", want: `{"text":"a\nb"}`},
+		{in: "<unknown>x", want: `{"text":"<unknown>x"}`},
+		{in: "<red>unclosed <bold", want: `{"text":"","extra":[{"text":"unclosed <bold","color":"red"}]}`},
+		{in: `\<red> \\ x`, want: `{"text":"<red> \\ x"}`},
+		{in: "§cx", want: `{"text":"§cx"}`},
+		{in: "<gradient:#ff0000:#0000ff>abc</gradient>", want: `{"text":"","extra":[{"text":"a","color":"#ff0000"},{"text":"b","color":"#800080"},{"text":"c","color":"#0000ff"}]}`},
+		{in: "<hover:show_text:'<red>tip'>x</hover>", want: `{"text":"","extra":[{"text":"x","hoverEvent":{"action":"show_text","contents":{"text":"","extra":[{"text":"tip","color":"red"}]},"value":{"text":"","extra":[{"text":"tip","color":"red"}]}}}]}`},
+		{in: "<click:suggest_command:'/msg '>x", want: `{"text":"","extra":[{"text":"x","clickEvent":{"action":"suggest_command","value":"/msg "}}]}`},
+	}
+	for _, test := range tests {
+		if got := componentJSON(FormatMessage(test.in, nil)); got != test.want {
+			t.Errorf("FormatMessage(%q) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestFormatMessageInputs(t *testing.T) {
+	colors := func(tag string) bool { return tag == TAG_COLOR }
+	tests := []struct {
+		name  string
+		input TagInput
+		want  string
+	}{
+		{name: "no tags", input: TagInput{Text: "<red>x"}, want: `{"text":"","extra":[{"text":"<red>x","bold":true}]}`},
+		{name: "allowed tags", input: TagInput{Text: "<red>x <click:run_command:'/op'>y", Allowed: colors}, want: `{"text":"","extra":[{"text":"x <click:run_command:'/op'>y","bold":true,"color":"red"}]}`},
+		{name: "closing the format", input: TagInput{Text: "</b>x", Allowed: colors}, want: `{"text":"","extra":[{"text":"</b>x","bold":true}]}`},
+	}
+	for _, test := range tests {
+		if got := componentJSON(FormatMessage("<b>%m%", map[string]TagInput{"%m%": test.input})); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
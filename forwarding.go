@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strings"
+
+	"github.com/Tnze/go-mc/data/packetid"
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+	"github.com/Tnze/go-mc/server/auth"
+	"github.com/Tnze/go-mc/yggdrasil/user"
+	"github.com/google/uuid"
+)
+
+const (
+	FORWARDING_NONE       = "none"
+	FORWARDING_BUNGEECORD = "bungeecord"
+	FORWARDING_VELOCITY   = "velocity"
+)
+
+const (
+	VelocityForwardingChannel = "velocity:player_info"
+	// the modern forwarding version without chat signing keys, which 1.19.3 and newer use
+	VelocityForwardingVersion = 1
+)
+
+var ErrNotForwarded = errors.New("the connection was not forwarded by the proxy")
+
+func ForwardingEnabled() bool {
+	mode := server.Config.Forwarding.Mode
+	return mode == FORWARDING_BUNGEECORD || mode == FORWARDING_VELOCITY
+}
+
+// reports whether addr is in one of the ips or ranges of proxies
+func IsTrustedProxy(addr string, proxies []string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, proxy := range proxies {
+		prefix, err := ParseIPBan(proxy)
+		if err == nil && prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parses the player info BungeeCord adds to the server address of the handshake: host\0ip\0uuid\0properties
+func ParseBungeeCordForwarding(address string) (string, *auth.Resp, error) {
+	parts := strings.Split(address, "\x00")
+	if len(parts) < 3 {
+		return "", nil, ErrNotForwarded
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid forwarded uuid %s", parts[2])
+	}
+	resp := &auth.Resp{ID: id, Properties: []user.Property{}}
+	if len(parts) > 3 {
+		if err := json.Unmarshal([]byte(parts[3]), &resp.Properties); err != nil {
+			return "", nil, fmt.Errorf("invalid forwarded properties: %s", err)
+		}
+	}
+	return parts[1], resp, nil
+}
+
+// asks Velocity for the player info with a login plugin request and verifies it against the forwarding secret
+func VelocityForwarding(conn *net.Conn) (string, *auth.Resp, error) {
+	secret := server.Config.Forwarding.Secret
+	if secret == "" {
+		return "", nil, errors.New("no forwarding secret is configured")
+	}
+	messageID := pk.VarInt(rand.Int31())
+	conn.WritePacket(pk.Marshal(packetid.LoginPluginRequest,
+		messageID,
+		pk.Identifier(VelocityForwardingChannel),
+		pk.Byte(VelocityForwardingVersion),
+	))
+	var p pk.Packet
+	if err := conn.ReadPacket(&p); err != nil {
+		return "", nil, err
+	}
+	var (
+		id         pk.VarInt
+		successful pk.Boolean
+		data       pk.PluginMessageData
+	)
+	if p.ID != packetid.LoginPluginResponse || p.Scan(&id, &successful, &data) != nil || id != messageID || !successful {
+		return "", nil, ErrNotForwarded
+	}
+	return ParseVelocityForwarding(data, secret)
+}
+
+// verifies the signature of the player info Velocity forwarded and parses it: version, address, uuid, name and properties
+func ParseVelocityForwarding(data []byte, secret string) (string, *auth.Resp, error) {
+	if len(data) < sha256.Size {
+		return "", nil, errors.New("forwarded player info is too short")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data[sha256.Size:])
+	if !hmac.Equal(mac.Sum(nil), data[:sha256.Size]) {
+		return "", nil, errors.New("forwarded player info has an invalid signature")
+	}
+	var (
+		version    pk.VarInt
+		address    pk.String
+		playerID   pk.UUID
+		name       pk.String
+		properties []user.Property
+	)
+	_, err := pk.Tuple{&version, &address, &playerID, &name, pk.Array(&properties)}.ReadFrom(bytes.NewReader(data[sha256.Size:]))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid forwarded player info: %s", err)
+	}
+	if version > VelocityForwardingVersion {
+		return "", nil, fmt.Errorf("unsupported forwarding version %d", version)
+	}
+	return string(address), &auth.Resp{Name: string(name), ID: uuid.UUID(playerID), Properties: properties}, nil
+}
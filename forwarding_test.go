@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	pk "github.com/Tnze/go-mc/net/packet"
+	"github.com/Tnze/go-mc/yggdrasil/user"
+	"github.com/google/uuid"
+)
+
+func velocityPlayerInfo(secret string, version int, properties []user.Property) []byte {
+	var payload bytes.Buffer
+	pk.Tuple{
+		pk.VarInt(version),
+		pk.String("203.0.113.7"),
+		pk.UUID(uuid.MustParse("069a79f4-44e9-4726-a5be-fca90e38aaf5")),
+		pk.String("Notch"),
+		pk.Array(properties),
+	}.WriteTo(&payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload.Bytes())
+	return append(mac.Sum(nil), payload.Bytes()...)
+}
+
+func TestParseVelocityForwarding(t *testing.T) {
+	properties := []user.Property{{Name: "textures", Value: "e30=", Signature: "c2ln"}}
+	valid := velocityPlayerInfo("secret", VelocityForwardingVersion, properties)
+	tampered := append([]byte{}, valid...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name   string
+		data   []byte
+		secret string
+		err    bool
+	}{
+		{name: "valid", data: valid, secret: "secret"},
+		{name: "no properties", data: velocityPlayerInfo("secret", VelocityForwardingVersion, nil), secret: "secret"},
+		{name: "wrong secret", data: valid, secret: "other", err: true},
+		{name: "forged", data: velocityPlayerInfo("forged", VelocityForwardingVersion, properties), secret: "secret", err: true},
+		{name: "tampered", data: tampered, secret: "secret", err: true},
+		{name: "newer version", data: velocityPlayerInfo("secret", VelocityForwardingVersion+1, properties), secret: "secret", err: true},
+		{name: "too short", data: valid[:sha256.Size-1], secret: "secret", err: true},
+		{name: "signature only", data: valid[:sha256.Size], secret: "secret", err: true},
+		{name: "empty", data: nil, secret: "secret", err: true},
+	}
+	for _, test := range tests {
+		address, resp, err := ParseVelocityForwarding(test.data, test.secret)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: got %s, want an error", test.name, address)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if address != "203.0.113.7" || resp.Name != "Notch" || resp.ID.String() != "069a79f4-44e9-4726-a5be-fca90e38aaf5" {
+			t.Errorf("%s: got %s %s %s", test.name, address, resp.Name, resp.ID)
+		}
+	}
+	_, resp, _ := ParseVelocityForwarding(valid, "secret")
+	if len(resp.Properties) != 1 || resp.Properties[0] != properties[0] {
+		t.Errorf("got properties %v, want %v", resp.Properties, properties)
+	}
+}
+
+func TestParseBungeeCordForwarding(t *testing.T) {
+	tests := []struct {
+		name       string
+		address    string
+		ip         string
+		id         string
+		properties int
+		err        bool
+	}{
+		{name: "dashed uuid", address: "play.example.com\x00203.0.113.7\x00069a79f4-44e9-4726-a5be-fca90e38aaf5", ip: "203.0.113.7", id: "069a79f4-44e9-4726-a5be-fca90e38aaf5"},
+		{name: "undashed uuid", address: "play.example.com\x00203.0.113.7\x00069a79f444e94726a5befca90e38aaf5", ip: "203.0.113.7", id: "069a79f4-44e9-4726-a5be-fca90e38aaf5"},
+		{name: "properties", address: "play.example.com\x00203.0.113.7\x00069a79f444e94726a5befca90e38aaf5\x00[{\"name\":\"textures\",\"value\":\"e30=\",\"signature\":\"c2ln\"}]", ip: "203.0.113.7", id: "069a79f4-44e9-4726-a5be-fca90e38aaf5", properties: 1},
+		{name: "not forwarded", address: "play.example.com", err: true},
+		{name: "missing uuid", address: "play.example.com\x00203.0.113.7", err: true},
+		{name: "invalid uuid", address: "play.example.com\x00203.0.113.7\x00Notch", err: true},
+		{name: "invalid properties", address: "play.example.com\x00203.0.113.7\x00069a79f444e94726a5befca90e38aaf5\x00{", err: true},
+	}
+	for _, test := range tests {
+		ip, resp, err := ParseBungeeCordForwarding(test.address)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: got %s, want an error", test.name, ip)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if ip != test.ip || resp.ID.String() != test.id || len(resp.Properties) != test.properties {
+			t.Errorf("%s: got %s %s %v", test.name, ip, resp.ID, resp.Properties)
+		}
+	}
+}
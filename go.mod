@@ -42,5 +42,6 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	golang.org/x/sys v0.11.0 // indirect
+	golang.org/x/term v0.10.0
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+type ipTreeNode struct {
+	Children [2]*ipTreeNode
+	Entry    int
+}
+
+// IPTree is a binary prefix tree that maps IP ranges to ban entry indexes
+type IPTree struct {
+	v4 *ipTreeNode
+	v6 *ipTreeNode
+}
+
+func NewIPTree() *IPTree {
+	return &IPTree{
+		v4: &ipTreeNode{Entry: -1},
+		v6: &ipTreeNode{Entry: -1},
+	}
+}
+
+func (tree *IPTree) root(addr netip.Addr) (*ipTreeNode, []byte) {
+	if addr.Is4() {
+		b := addr.As4()
+		return tree.v4, b[:]
+	}
+	b := addr.As16()
+	return tree.v6, b[:]
+}
+
+func (tree *IPTree) Insert(prefix netip.Prefix, entry int) {
+	node, bytes := tree.root(prefix.Addr())
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := bytes[i/8] >> (7 - i%8) & 1
+		if node.Children[bit] == nil {
+			node.Children[bit] = &ipTreeNode{Entry: -1}
+		}
+		node = node.Children[bit]
+	}
+	node.Entry = entry
+}
+
+// returns the entries of every range containing the address, the most specific one last
+func (tree *IPTree) Lookup(addr netip.Addr) []int {
+	addr = addr.Unmap()
+	node, bytes := tree.root(addr)
+	entries := []int{}
+	if node.Entry != -1 {
+		entries = append(entries, node.Entry)
+	}
+	for i := 0; i < len(bytes)*8; i++ {
+		node = node.Children[bytes[i/8]>>(7-i%8)&1]
+		if node == nil {
+			break
+		}
+		if node.Entry != -1 {
+			entries = append(entries, node.Entry)
+		}
+	}
+	return entries
+}
+
+func NewIPBanTree(list []BanEntry) *IPTree {
+	tree := NewIPTree()
+	for i, entry := range list {
+		prefix, err := ParseIPBan(entry.IP)
+		if err != nil {
+			server.Logger.Warn("Ignoring invalid IP ban %s", entry.IP)
+			continue
+		}
+		tree.Insert(prefix, i)
+	}
+	return tree
+}
+
+// parses a single address (1.2.3.4), a CIDR block (10.0.0.0/8, 2001:db8::/32) or a wildcard (192.168.*.*)
+func ParseIPBan(str string) (netip.Prefix, error) {
+	if strings.Contains(str, "*") {
+		parts := strings.Split(str, ".")
+		if len(parts) != 4 {
+			return netip.Prefix{}, fmt.Errorf("invalid wildcard %s", str)
+		}
+		bits := 0
+		for i, part := range parts {
+			if part == "*" {
+				parts[i] = "0"
+				continue
+			}
+			if bits != i*8 {
+				return netip.Prefix{}, fmt.Errorf("invalid wildcard %s", str)
+			}
+			bits += 8
+		}
+		addr, err := netip.ParseAddr(strings.Join(parts, "."))
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, bits), nil
+	}
+	if strings.Contains(str, "/") {
+		prefix, err := netip.ParsePrefix(str)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(str)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// returns the normalized form of an IP ban that is stored in banned-ips.json
+func NormalizeIPBan(str string) (string, error) {
+	prefix, err := ParseIPBan(str)
+	if err != nil {
+		return "", err
+	}
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String(), nil
+	}
+	return prefix.String(), nil
+}
+
+// returns the address without the port
+func RemoteAddress(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
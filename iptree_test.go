@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIPBan(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "1.2.3.4", want: "1.2.3.4/32"},
+		{in: "::ffff:1.2.3.4", want: "1.2.3.4/32"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{in: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{in: "::ffff:10.0.0.0/104", want: "10.0.0.0/8"},
+		{in: "2001:db8::1/32", want: "2001:db8::/32"},
+		{in: "192.168.*.*", want: "192.168.0.0/16"},
+		{in: "10.*.*.*", want: "10.0.0.0/8"},
+		{in: "*.*.*.*", want: "0.0.0.0/0"},
+		{in: "1.*.3.*", err: true},
+		{in: "1.2.*", err: true},
+		{in: "10.0.0.0/33", err: true},
+		{in: "localhost", err: true},
+		{in: "", err: true},
+	}
+	for _, test := range tests {
+		prefix, err := ParseIPBan(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseIPBan(%q) = %s, want an error", test.in, prefix)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIPBan(%q) failed: %s", test.in, err)
+			continue
+		}
+		if prefix.String() != test.want {
+			t.Errorf("ParseIPBan(%q) = %s, want %s", test.in, prefix, test.want)
+		}
+	}
+}
+
+func TestNormalizeIPBan(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "1.2.3.4", want: "1.2.3.4"},
+		{in: "1.2.3.4/32", want: "1.2.3.4"},
+		{in: "10.0.0.1/8", want: "10.0.0.0/8"},
+		{in: "192.168.*.*", want: "192.168.0.0/16"},
+		{in: "::ffff:1.2.3.4", want: "1.2.3.4"},
+	}
+	for _, test := range tests {
+		got, err := NormalizeIPBan(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("NormalizeIPBan(%q) = %q, %v, want %q", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestIPBanLookup(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).Format(BanTimeFormat)
+	list := []BanEntry{
+		{IP: "10.0.0.0/8", Expires: "forever", Reason: "wide"},
+		{IP: "10.1.0.0/16", Expires: expired, Reason: "expired range"},
+		{IP: "10.1.2.3", Expires: expired, Reason: "expired address"},
+		{IP: "192.168.*.*", Expires: "forever", Reason: "wildcard"},
+		{IP: "192.168.1.1", Expires: "forever", Reason: "address"},
+		{IP: "2001:db8::/32", Expires: "forever", Reason: "v6"},
+		{IP: "not an ip", Expires: "forever", Reason: "invalid"},
+	}
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "10.1.2.3", want: "wide"},
+		{ip: "10.1.9.9", want: "wide"},
+		{ip: "10.200.0.1", want: "wide"},
+		{ip: "192.168.1.1", want: "address"},
+		{ip: "192.168.5.5", want: "wildcard"},
+		{ip: "::ffff:192.168.5.5", want: "wildcard"},
+		{ip: "2001:db8:1::1", want: "v6"},
+		{ip: "11.0.0.1", want: ""},
+		{ip: "2001:db9::1", want: ""},
+		{ip: "garbage", want: ""},
+	}
+	var players PlayersC
+	players.SetBannedIPs(list)
+	for _, test := range tests {
+		var got string
+		if ban := players.getBan("", test.ip); ban != nil {
+			got = ban.Reason
+		}
+		if got != test.want {
+			t.Errorf("ban of %s = %q, want %q", test.ip, got, test.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -23,6 +24,15 @@ type Logger struct {
 	FilePath    string
 	ConsoleText []string
 	GUIConsole  *widget.TextGrid
+	// Out is where log lines are written, stdout if nil
+	Out io.Writer
+}
+
+func (logger *Logger) out() io.Writer {
+	if logger.Out == nil {
+		return os.Stdout
+	}
+	return logger.Out
 }
 
 func (logger *Logger) Append(str string) {
@@ -41,13 +51,13 @@ func (logger *Logger) Info(format string, a ...interface{}) {
 	time := getDateString()
 	str := fmt.Sprintf(format, a...)
 	logger.Append(fmt.Sprintf("[%s INFO]: %s", time, str))
-	fmt.Printf("[%s %s]: %s\n", time, blue("INFO"), str)
+	fmt.Fprintf(logger.out(), "[%s %s]: %s\n", time, blue("INFO"), str)
 }
 
 func (logger *Logger) Print(format string, a ...interface{}) {
 	format += "\n"
 	logger.Append(format)
-	fmt.Printf(format, a...)
+	fmt.Fprintf(logger.out(), format, a...)
 }
 
 func (logger *Logger) Debug(format string, a ...interface{}) {
@@ -58,7 +68,7 @@ func (logger *Logger) Debug(format string, a ...interface{}) {
 	str := fmt.Sprintf(format, a...)
 	time := getDateString()
 	logger.Append(fmt.Sprintf("[%s DEBUG]: %s", time, str))
-	fmt.Printf("[%s %s]: %s\n", time, cyan("DEBUG"), str)
+	fmt.Fprintf(logger.out(), "[%s %s]: %s\n", time, cyan("DEBUG"), str)
 }
 
 func (logger *Logger) Error(format string, a ...interface{}) {
@@ -67,7 +77,7 @@ func (logger *Logger) Error(format string, a ...interface{}) {
 	time := getDateString()
 	str := fmt.Sprintf(format, a...)
 	logger.Append(fmt.Sprintf("[%s ERROR]: %s", time, str))
-	fmt.Printf("[%s %s]: %s\n", time, red("ERROR"), str)
+	fmt.Fprintf(logger.out(), "[%s %s]: %s\n", time, red("ERROR"), str)
 }
 
 func (logger *Logger) Warn(format string, a ...interface{}) {
@@ -76,5 +86,5 @@ func (logger *Logger) Warn(format string, a ...interface{}) {
 	time := getDateString()
 	str := fmt.Sprintf(format, a...)
 	logger.Append(fmt.Sprintf("[%s WARN]: %s", time, str))
-	fmt.Printf("[%s %s]: %s\n", time, yellow("WARN"), str)
+	fmt.Fprintf(logger.out(), "[%s %s]: %s\n", time, yellow("WARN"), str)
 }
@@ -3,7 +3,6 @@ package main
 import (
 	"dynamite/logger"
 	"embed"
-	"fmt"
 	"os"
 	"os/signal"
 	"time"
@@ -12,14 +11,15 @@ import (
 )
 
 var server = Server{
-	Logger: &logger.Logger{},
-	Config: LoadConfig(),
+	Logger:  &logger.Logger{},
+	Console: &Console{},
 	Players: PlayersC{
 		Players:     make(map[string]*Player),
 		PlayerNames: make(map[string]string),
 		PlayerIDs:   make([]string, 0),
 	},
-	Events: Events{_Events: make(map[string][]func(...interface{}))},
+	Events:   Events{_Events: make(map[string][]func(...interface{}))},
+	Channels: NewChannels(),
 	Commands: map[string]Command{
 		"gamemode": {
 			Name:                "gamemode",
@@ -39,6 +39,7 @@ var server = Server{
 						Name:       "minecraft:entity",
 						Properties: pk.Byte(0x02),
 					},
+					Optional: true,
 				},
 			},
 		},
@@ -48,18 +49,28 @@ var server = Server{
 			Aliases:             []string{"tp"},
 			Arguments: []Argument{
 				{
-					Name: "one",
+					Name: "targets",
 					Parser: Parser{
-						ID:   8,
-						Name: "minecraft:block_pos",
+						ID:         6,
+						Name:       "minecraft:entity",
+						Properties: pk.Byte(0),
+					},
+				},
+				{
+					Name: "location",
+					Parser: Parser{
+						ID:   10,
+						Name: "minecraft:vec3",
 					},
+					Optional: true,
 				},
 				{
-					Name: "two",
+					Name: "rotation",
 					Parser: Parser{
-						ID:   8,
-						Name: "minecraft:block_pos",
+						ID:   27,
+						Name: "minecraft:rotation",
 					},
+					Optional: true,
 				},
 			},
 		},
@@ -75,6 +86,324 @@ var server = Server{
 						Properties: pk.Byte(0x02),
 					},
 				},
+				{
+					Name: "level",
+					Parser: Parser{
+						ID:         3,
+						Name:       "brigadier:integer",
+						Properties: pk.Tuple{pk.Byte(0x03), pk.Int(1), pk.Int(4)},
+					},
+					Optional: true,
+				},
+			},
+		},
+		"deop": {
+			Name:                "deop",
+			RequiredPermissions: []string{"server.command.deop"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+				},
+			},
+		},
+		"ban": {
+			Name:                "ban",
+			RequiredPermissions: []string{"server.command.ban"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+				},
+				{
+					Name: "reason",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+					Optional: true,
+				},
+			},
+		},
+		"tempban": {
+			Name:                "tempban",
+			RequiredPermissions: []string{"server.command.ban"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+				},
+				{
+					Name: "duration",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+				},
+				{
+					Name: "reason",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+					Optional: true,
+				},
+			},
+		},
+		"ban-ip": {
+			Name:                "ban-ip",
+			RequiredPermissions: []string{"server.command.ban-ip"},
+			Arguments: []Argument{
+				{
+					Name: "target",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+					SuggestionsType: "minecraft:ask_server",
+				},
+				{
+					Name: "reason",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+					Optional: true,
+				},
+			},
+		},
+		"pardon": {
+			Name:                "pardon",
+			RequiredPermissions: []string{"server.command.pardon"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+					SuggestionsType: "minecraft:ask_server",
+				},
+			},
+		},
+		"pardon-ip": {
+			Name:                "pardon-ip",
+			RequiredPermissions: []string{"server.command.pardon-ip"},
+			Arguments: []Argument{
+				{
+					Name: "target",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+					SuggestionsType: "minecraft:ask_server",
+				},
+			},
+		},
+		"mute": {
+			Name:                "mute",
+			RequiredPermissions: []string{"server.command.mute"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+				},
+				{
+					Name: "reason",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+					Optional: true,
+				},
+			},
+		},
+		"tempmute": {
+			Name:                "tempmute",
+			RequiredPermissions: []string{"server.command.mute"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+				},
+				{
+					Name: "duration",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+				},
+				{
+					Name: "reason",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+					Optional: true,
+				},
+			},
+		},
+		"unmute": {
+			Name:                "unmute",
+			RequiredPermissions: []string{"server.command.mute"},
+			Arguments: []Argument{
+				{
+					Name: "player",
+					Parser: Parser{
+						ID:   7,
+						Name: "minecraft:game_profile",
+					},
+					SuggestionsType: "minecraft:ask_server",
+				},
+			},
+		},
+		"channel": {
+			Name:                "channel",
+			RequiredPermissions: []string{"server.command.channel"},
+			Aliases:             []string{"ch"},
+			Arguments: []Argument{
+				{
+					Name: "action",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+					SuggestionsType: "minecraft:ask_server",
+				},
+				{
+					Name: "channel",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+					SuggestionsType: "minecraft:ask_server",
+					Optional:        true,
+				},
+				{
+					Name: "message",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+					Optional: true,
+				},
+			},
+		},
+		"delete": {
+			Name:                "delete",
+			RequiredPermissions: []string{"server.command.delete"},
+			Arguments: []Argument{
+				{
+					Name: "id",
+					Parser: Parser{
+						ID:         3,
+						Name:       "brigadier:integer",
+						Properties: pk.Tuple{pk.Byte(0x01), pk.Int(1)},
+					},
+				},
+			},
+		},
+		"msg": {
+			Name:                "msg",
+			RequiredPermissions: []string{"server.command.msg"},
+			Aliases:             []string{"tell", "w"},
+			Arguments: []Argument{
+				{
+					Name: "targets",
+					Parser: Parser{
+						ID:         6,
+						Name:       "minecraft:entity",
+						Properties: pk.Byte(0x02),
+					},
+				},
+				{
+					Name: "message",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+				},
+			},
+		},
+		"r": {
+			Name:                "r",
+			RequiredPermissions: []string{"server.command.msg"},
+			Arguments: []Argument{
+				{
+					Name: "message",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+				},
+			},
+		},
+		"me": {
+			Name:                "me",
+			RequiredPermissions: []string{"server.command.me"},
+			Arguments: []Argument{
+				{
+					Name: "action",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+				},
+			},
+		},
+		"say": {
+			Name:                "say",
+			RequiredPermissions: []string{"server.command.say"},
+			Arguments: []Argument{
+				{
+					Name: "message",
+					Parser: Parser{
+						ID:   18,
+						Name: "minecraft:message",
+					},
+				},
+			},
+		},
+		"tellraw": {
+			Name:                "tellraw",
+			RequiredPermissions: []string{"server.command.tellraw"},
+			Arguments: []Argument{
+				{
+					Name: "targets",
+					Parser: Parser{
+						ID:         6,
+						Name:       "minecraft:entity",
+						Properties: pk.Byte(0x02),
+					},
+				},
+				{
+					Name: "message",
+					Parser: Parser{
+						ID:   17,
+						Name: "minecraft:component",
+					},
+				},
 			},
 		},
 		"reload": {
@@ -86,6 +415,23 @@ var server = Server{
 			Name:                "stop",
 			RequiredPermissions: []string{"server.command.stop"},
 		},
+		"help": {
+			Name:                "help",
+			RequiredPermissions: []string{},
+			Aliases:             []string{"?"},
+			Arguments: []Argument{
+				{
+					Name: "command",
+					Parser: Parser{
+						ID:         5,
+						Name:       "brigadier:string",
+						Properties: pk.VarInt(0),
+					},
+					SuggestionsType: "minecraft:ask_server",
+					Optional:        true,
+				},
+			},
+		},
 		"ram": {
 			Name:                "ram",
 			RequiredPermissions: []string{},
@@ -96,21 +442,19 @@ var server = Server{
 //go:embed registry.nbt
 var registries embed.FS
 
-func init() {
+func main() {
 	server.StartTime = time.Now().Unix()
 	server.Logger.Info("Starting Dynamite")
+	server.Config = LoadConfig()
 	server.Init()
-}
-
-func main() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		for range c {
-			fmt.Println(server.Command("console", "stop"))
+			server.Logger.Print("%v", server.Command("console", "stop"))
 		}
 	}()
-	go CreateSTDINReader()
+	go server.Console.Start()
 	if logger.HasArg("-gui") {
 		go func() {
 			for {
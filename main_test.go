@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the parts of server.Init the tests need, without a world or any files
+	server.Config = DefaultConfig()
+	server.Players.Mutex = &sync.Mutex{}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FILTER_REPLACE = "replace"
+	FILTER_BLOCK   = "block"
+)
+
+// the window the chat rate limit counts messages in
+const ChatRateLimitWindow = 10 * time.Second
+
+var chatLimiter = NewRateLimiter(ChatRateLimitWindow)
+
+// compiled filter patterns, nil for invalid ones so they are only reported once
+var compiledFilters sync.Map
+
+// compiles the pattern of a filter once, plain words match case insensitively on word boundaries
+func compileFilter(filter ChatFilter) *regexp.Regexp {
+	pattern := filter.Pattern
+	if !filter.Regex {
+		pattern = `(?i)\b` + regexp.QuoteMeta(pattern) + `\b`
+	}
+	if re, ok := compiledFilters.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		server.Logger.Warn("[Chat] Invalid filter %s: %s", filter.Pattern, err)
+		re = nil
+	}
+	compiledFilters.Store(pattern, re)
+	return re
+}
+
+// applies the chat filters to content, returns false if a blocking filter matched
+func FilterChat(content string) (bool, string) {
+	for _, filter := range server.Config.Chat.Filters {
+		re := compileFilter(filter)
+		if re == nil || !re.MatchString(content) {
+			continue
+		}
+		if filter.Action == FILTER_BLOCK {
+			return false, content
+		}
+		content = re.ReplaceAllStringFunc(content, func(match string) string {
+			if filter.Replacement == "" {
+				return strings.Repeat("*", utf8.RuneCountInString(match))
+			}
+			return filter.Replacement
+		})
+	}
+	return true, content
+}
+
+// checks mutes, spam and the filters before a message is broadcast, returns false and a message for the sender if it's blocked
+func (server Server) ModerateChat(player *Player, message *ChatMessage) (bool, string) {
+	if mute := server.Players.GetMute(player.UUID.String); mute != nil {
+		return false, mute.MuteMessage()
+	}
+	if server.HasPermissions(player.UUID.String, []string{"server.chat.bypass"}) {
+		return true, ""
+	}
+	spam := server.Config.Chat.AntiSpam
+	if !chatLimiter.Allow(player.UUID.String, spam.RateLimit) {
+		return false, server.Config.Messages.ChatTooFast
+	}
+	if spam.RepeatLimit > 0 {
+		// only the player's own connection handles its chat, so these don't need a lock
+		normalized := strings.ToLower(strings.TrimSpace(message.Content))
+		if normalized == player.LastChatMessage {
+			player.ChatRepeats++
+		} else {
+			player.LastChatMessage = normalized
+			player.ChatRepeats = 1
+		}
+		if player.ChatRepeats > spam.RepeatLimit {
+			return false, server.Config.Messages.ChatRepeated
+		}
+	}
+	ok, content := FilterChat(message.Content)
+	if !ok {
+		server.Logger.Info("[Chat] Blocked message from %s (%s): %s", player.Name, player.UUID.String, message.Content)
+		return false, server.Config.Messages.ChatFiltered
+	}
+	message.Content = content
+	return true, ""
+}
+
+// checks the anti-spam and filters for messages sent with commands, returns false and a message for the sender
+// if it's blocked or true and the filtered message
+func (server Server) ModerateCommand(executor string, message string) (bool, string) {
+	if server.HasPermissions(executor, []string{"server.chat.bypass"}) {
+		return true, message
+	}
+	if !chatLimiter.Allow(executor, server.Config.Chat.AntiSpam.RateLimit) {
+		return false, server.Config.Messages.ChatTooFast
+	}
+	ok, content := FilterChat(message)
+	if !ok {
+		server.Logger.Info("[Chat] Blocked command message from %s: %s", server.GetName(executor), message)
+		return false, server.Config.Messages.ChatFiltered
+	}
+	return true, content
+}
+
+func NewMuteEntry(source string, reason string, expires time.Time) BanEntry {
+	if reason == "" {
+		reason = "Muted by an operator."
+	}
+	return NewBanEntry(source, reason, expires)
+}
+
+func (entry BanEntry) MuteMessage() string {
+	message := server.Config.Messages.Muted
+	if _, temporary := entry.ExpiresAt(); temporary {
+		message = server.Config.Messages.TempMuted
+	}
+	return ParsePlaceholders(message, Placeholders{Reason: entry.Reason, Expires: entry.Expires})
+}
+
+const MutedPlayersFile = "muted_players.json"
+
+func (players *PlayersC) LoadMutes() {
+	list := LoadBanList(MutedPlayersFile)
+	players.Lock()
+	defer players.Unlock()
+	players.MutedPlayers = list
+}
+
+// mutes a player and saves the list, returns false if it's already muted
+func (players *PlayersC) AddMute(entry BanEntry) bool {
+	players.Lock()
+	defer players.Unlock()
+	if players.getMute(entry.UUID) != nil {
+		return false
+	}
+	players.MutedPlayers = append(players.MutedPlayers, entry)
+	WriteBanList(MutedPlayersFile, players.MutedPlayers)
+	return true
+}
+
+// unmutes a player by uuid or name and saves the list, returns the removed mute
+func (players *PlayersC) RemoveMute(id string) (bool, BanEntry) {
+	players.Lock()
+	defer players.Unlock()
+	for i, entry := range players.MutedPlayers {
+		if entry.UUID == id || strings.EqualFold(entry.Name, id) {
+			players.MutedPlayers = append(players.MutedPlayers[:i:i], players.MutedPlayers[i+1:]...)
+			WriteBanList(MutedPlayersFile, players.MutedPlayers)
+			return true, entry
+		}
+	}
+	return false, BanEntry{}
+}
+
+// returns a copy of the active mute of a player, nil if there is none
+func (players *PlayersC) GetMute(id string) *BanEntry {
+	players.Lock()
+	defer players.Unlock()
+	if mute := players.getMute(id); mute != nil {
+		entry := *mute
+		return &entry
+	}
+	return nil
+}
+
+func (players *PlayersC) getMute(id string) *BanEntry {
+	for i, entry := range players.MutedPlayers {
+		if entry.UUID == id && !entry.Expired() {
+			return &players.MutedPlayers[i]
+		}
+	}
+	return nil
+}
+
+// removes expired mutes and saves the list if anything changed
+func (server *Server) PruneMutes() {
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	list := []BanEntry{}
+	for _, entry := range server.Players.MutedPlayers {
+		if !entry.Expired() {
+			list = append(list, entry)
+		}
+	}
+	if len(list) != len(server.Players.MutedPlayers) {
+		server.Players.MutedPlayers = list
+		WriteBanList(MutedPlayersFile, list)
+	}
+}
+
+func mutedPlayerNames(executor string) []string {
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	names := []string{}
+	for _, entry := range server.Players.MutedPlayers {
+		names = append(names, entry.Name)
+	}
+	return names
+}
@@ -11,9 +11,30 @@ import (
 		server.command.stop - /stop command
 		server.command.reload - /reload command
 		server.command.op - /op command
+		server.command.deop - /deop command
 		server.command.gamemode - /gamemode command
+		server.command.teleport - /teleport command
+		server.command.ban - /ban and /tempban commands
+		server.command.ban-ip - /ban-ip command
+		server.command.pardon - /pardon command
+		server.command.pardon-ip - /pardon-ip command
+		server.command.mute - /mute, /tempmute and /unmute commands
+		server.command.delete - /delete command
+		server.command.msg - /msg, /tell, /w and /r commands
+		server.command.channel - /channel command
+		server.command.me - /me command
+		server.command.say - /say command
+		server.command.tellraw - /tellraw command
 		server.chat - Use chat
-		server.chat.colors - Use chat colors
+		server.chat.colors - Use the color, decoration, gradient and reset tags in chat
+		server.chat.tags.<tag> - Use a tag in chat, e.g. server.chat.tags.hover or server.chat.tags.click
+		server.chat.bypass - Bypass the chat filters and anti-spam
+		server.chat.staff - Read and talk in the staff chat channel
+		* - All permissions
+
+	Ops are additionally granted the permissions of the op_1 to op_<level> groups.
+	Ops can only op and deop players below their own level, players that have server.command.op
+	without being ops can manage ops up to op_permission_level.
 */
 
 var groupCache = make(map[string]GroupPermissions)
@@ -58,24 +79,110 @@ func getGroup(group string) GroupPermissions {
 	return data
 }
 
+// default permissions for each op level, ops are granted the groups of their level and every level below it
+var opGroups = map[int]GroupPermissions{
+	1: {
+		DisplayName: "op level 1",
+		Permissions: map[string]bool{
+			"server.chat":        true,
+			"server.chat.colors": true,
+		},
+	},
+	2: {
+		DisplayName: "op level 2",
+		Permissions: map[string]bool{
+			"server.command.gamemode": true,
+			"server.command.teleport": true,
+			"server.command.say":      true,
+			"server.command.tellraw":  true,
+		},
+	},
+	3: {
+		DisplayName: "op level 3",
+		Permissions: map[string]bool{
+			"server.command.ban":       true,
+			"server.command.ban-ip":    true,
+			"server.command.pardon":    true,
+			"server.command.pardon-ip": true,
+			"server.command.op":        true,
+			"server.command.deop":      true,
+			"server.command.delete":    true,
+			"server.command.mute":      true,
+		},
+	},
+	4: {
+		DisplayName: "op level 4",
+		Permissions: map[string]bool{
+			"*": true,
+		},
+	},
+}
+
+func CreateOPGroups() {
+	for level, group := range opGroups {
+		path := fmt.Sprintf("permissions/groups/op_%d.json", level)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		data, _ := json.Marshal(group)
+		os.WriteFile(path, data, 0755)
+	}
+}
+
+func hasPermission(permissions map[string]bool, perm string) bool {
+	return permissions[perm] || permissions["*"]
+}
+
+// returns the op level of a player or 0 if it isn't an op, console and rcon are above every level
+func (server Server) OPLevel(id string) int {
+	if id == "console" || id == "rcon" {
+		return 5
+	}
+	if isOp, op := server.Players.IsOP(id); isOp {
+		return op.Level
+	}
+	return 0
+}
+
+// returns the level a player can op and deop others below, players with the op permission that aren't ops
+// themselves manage ops up to the default op level
+func (server Server) OPManagerLevel(id string) int {
+	level := server.OPLevel(id)
+	if level == 0 && server.HasPermissions(id, []string{"server.command.op"}) {
+		return server.Config.OPPermissionLevel + 1
+	}
+	return level
+}
+
 func (server Server) HasPermissions(playerId string, perms []string) bool {
-	if playerId == "console" {
+	if playerId == "console" || playerId == "rcon" {
 		return true
 	}
 	if len(perms) == 0 {
 		return true
 	}
+	groups := []GroupPermissions{}
 	for i := 0; i < len(server.Players.OPs); i++ {
 		if server.Players.OPs[i].UUID == playerId {
-			return true
+			for level := 1; level <= server.Players.OPs[i].Level; level++ {
+				groups = append(groups, getGroup(fmt.Sprintf("op_%d", level)))
+			}
+			break
 		}
 	}
 	permissionsPlayer := getPlayer(playerId)
-	permissionsGroup := getGroup(permissionsPlayer.Group)
+	groups = append(groups, getGroup(permissionsPlayer.Group))
+Permissions:
 	for _, perm := range perms {
-		if !permissionsPlayer.Permissions[perm] && !permissionsGroup.Permissions[perm] {
-			return false
+		if hasPermission(permissionsPlayer.Permissions, perm) {
+			continue
+		}
+		for _, group := range groups {
+			if hasPermission(group.Permissions, perm) {
+				continue Permissions
+			}
 		}
+		return false
 	}
 	return true
 }
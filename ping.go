@@ -1,9 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Tnze/go-mc/chat"
+	"github.com/Tnze/go-mc/net"
 )
 
+// legacy clients compare this to their own protocol, 127 is never a match so they show the version name instead
+const LEGACY_PING_PROTOCOL = 127
+
+const LegacyPingTimeout = 500 * time.Millisecond
+
 type Version struct {
 	Name     string `json:"name"`
 	Protocol int    `json:"protocol"`
@@ -12,20 +30,194 @@ type Version struct {
 type Players struct {
 	Max    int          `json:"max"`
 	Online int          `json:"online"`
-	Sample []PlayerBase `json:"sample"`
+	Sample []PlayerBase `json:"sample,omitempty"`
 }
 
-type Description struct {
-	Text string `json:"text"`
+type StatusResponse struct {
+	Version            Version      `json:"version"`
+	Players            *Players     `json:"players,omitempty"`
+	Description        chat.Message `json:"description"`
+	EnforcesSecureChat bool         `json:"enforcesSecureChat"`
+	PreviewsChat       bool         `json:"previewsChat"`
+	Favicon            string       `json:"favicon"`
 }
 
-type StatusResponse struct {
-	Version            Version     `json:"version"`
-	Players            Players     `json:"players"`
-	Description        Description `json:"description"`
-	EnforcesSecureChat bool        `json:"enforcesSecureChat"`
-	PreviewsChat       bool        `json:"previewsChat"`
-	Favicon            string      `json:"favicon"`
+const MinecraftVersion = "1.19.4"
+
+// returns the max players shown in the server list, -1 in the config shows one more than the online count
+func (server *Server) GetMaxPlayers() int {
+	if server.Config.MaxPlayers == -1 {
+		return len(server.Players.Players) + 1
+	}
+	return server.Config.MaxPlayers
+}
+
+func (server *Server) StatusPlaceholders(protocol int) Placeholders {
+	return Placeholders{
+		Online:     fmt.Sprint(len(server.Players.AsBase())),
+		MaxPlayers: fmt.Sprint(server.GetMaxPlayers()),
+		TPS:        fmt.Sprint(tps.Load()),
+		Time:       time.Now().Format("15:04"),
+		Version:    MinecraftVersion,
+		Protocol:   fmt.Sprint(protocol),
+	}
+}
+
+// parses chat component json, or text using tags and § or & color codes
+func ParseTextComponent(str string) chat.Message {
+	if trimmed := strings.TrimSpace(str); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var message chat.Message
+		if err := message.UnmarshalJSON([]byte(trimmed)); err == nil {
+			return message
+		}
+		server.Logger.Warn("Invalid chat component %s", trimmed)
+	}
+	return FormatMessage(TranslateColorCodes(str), nil)
+}
+
+// replaces & color codes with § color codes
+func TranslateColorCodes(str string) string {
+	runes := []rune(str)
+	for i := 0; i < len(runes)-1; i++ {
+		if runes[i] == '&' && strings.ContainsRune("0123456789abcdefklmnorABCDEFKLMNOR", runes[i+1]) {
+			runes[i] = '§'
+		}
+	}
+	return string(runes)
+}
+
+// returns the configured MOTDs, the motds list replaces the single motd when it isn't empty
+func (server *Server) MOTDs() []string {
+	if len(server.Config.MOTDs) > 0 {
+		return server.Config.MOTDs
+	}
+	return []string{server.Config.MOTD}
+}
+
+// returns the MOTD for a client, a random one is picked when several are configured
+func (server *Server) GetMOTD(protocol int) chat.Message {
+	motds := server.MOTDs()
+	return server.parseMOTD(motds[rand.Intn(len(motds))], protocol)
+}
+
+func (server *Server) parseMOTD(motd string, protocol int) chat.Message {
+	return ParseTextComponent(ParsePlaceholders(motd, server.StatusPlaceholders(protocol)))
+}
+
+func (server *Server) GetVersionName(protocol int) string {
+	return TranslateColorCodes(ParsePlaceholders(server.Config.VersionName, server.StatusPlaceholders(protocol)))
+}
+
+// returns the players shown in the server list, or nil if online counts are hidden
+func (server *Server) GetStatusPlayers(protocol int) *Players {
+	config := server.Config.ServerList
+	if config.HideOnline {
+		return nil
+	}
+	players := server.Players.AsBase()
+	status := &Players{
+		Max:    server.GetMaxPlayers(),
+		Online: len(players),
+	}
+	switch {
+	case config.Sample.Hide:
+	case len(config.Sample.Custom) > 0:
+		{
+			placeholders := server.StatusPlaceholders(protocol)
+			for _, line := range config.Sample.Custom {
+				status.Sample = append(status.Sample, PlayerBase{
+					Name: TranslateColorCodes(ParsePlaceholders(line, placeholders)),
+					UUID: "00000000-0000-0000-0000-000000000000",
+				})
+			}
+		}
+	default:
+		{
+			rand.Shuffle(len(players), func(i, j int) { players[i], players[j] = players[j], players[i] })
+			if config.Sample.Max > 0 && len(players) > config.Sample.Max {
+				players = players[:config.Sample.Max]
+			}
+			status.Sample = players
+		}
+	}
+	return status
+}
+
+const (
+	StatusCacheLifetime = 5 * time.Second
+	StatusCacheSize     = 32
+)
+
+type cachedStatus struct {
+	// one finished response for every MOTD
+	Responses []string
+	Created   time.Time
+}
+
+// the status responses by client protocol, cleared when players join or leave and on reload
+var statusCache = make(map[int]cachedStatus)
+var statusCacheLock sync.Mutex
+
+// status pings per ip in every minute, limited by server_list.rate_limit
+var pingLimiter = NewRateLimiter(time.Minute)
+
+func InvalidateStatusCache() {
+	statusCacheLock.Lock()
+	defer statusCacheLock.Unlock()
+	statusCache = make(map[int]cachedStatus)
+}
+
+// returns one of the cached responses, so the motd still rotates between pings
+func (server *Server) GetStatusResponse(protocol int) string {
+	statusCacheLock.Lock()
+	defer statusCacheLock.Unlock()
+	cached, ok := statusCache[protocol]
+	if !ok || time.Since(cached.Created) >= StatusCacheLifetime {
+		cached = cachedStatus{Responses: server.createStatusResponses(protocol), Created: time.Now()}
+		// clients choose the protocol, so don't let the cache grow without bound
+		if len(statusCache) >= StatusCacheSize {
+			statusCache = make(map[int]cachedStatus)
+		}
+		statusCache[protocol] = cached
+	}
+	return cached.Responses[rand.Intn(len(cached.Responses))]
+}
+
+// renders the status response once for every MOTD, each with its own player sample
+func (server *Server) createStatusResponses(protocol int) []string {
+	response := StatusResponse{
+		Version: Version{
+			Name:     server.GetVersionName(protocol),
+			Protocol: PROTOCOL_1_19_4,
+		},
+		EnforcesSecureChat: EnforcesSecureChat(),
+		PreviewsChat:       false,
+	}
+	if server.Config.Icon.Enable {
+		success, code, data := server.GetFavicon()
+		if !success {
+			switch code {
+			case FAVICON_NOTFOUND:
+				{
+					server.Logger.Warn("Server icon is enabled but wasn't found; ignoring")
+				}
+			case FAVICON_INVALID_FORMAT, FAVICON_INVALID_DIMENSIONS:
+				{
+					server.Logger.Debug("Server icon is not a 64x64 png file; ignoring")
+				}
+			}
+		} else {
+			icon := base64.StdEncoding.EncodeToString(data)
+			response.Favicon = fmt.Sprintf("data:image/png;base64,%s", icon)
+		}
+	}
+	var responses []string
+	for _, motd := range server.MOTDs() {
+		response.Description = server.parseMOTD(motd, protocol)
+		response.Players = server.GetStatusPlayers(protocol)
+		responses = append(responses, CreateStatusResponse(response))
+	}
+	return responses
 }
 
 func CreateStatusResponse(data StatusResponse) string {
@@ -35,3 +227,50 @@ func CreateStatusResponse(data StatusResponse) string {
 	}
 	return string(buffer)
 }
+
+// reads the 0xFA MC|PingHost plugin message 1.6 clients send after 0xFE 0x01, 1.4 and 1.5 don't send it
+// so this returns once the read deadline passes
+func readLegacyPingHost(r io.Reader) {
+	id := make([]byte, 1)
+	if _, err := io.ReadFull(r, id); err != nil || id[0] != 0xFA {
+		return
+	}
+	// the channel name as UTF-16 with its length in characters, then the data with its length in bytes
+	var length uint16
+	if binary.Read(r, binary.BigEndian, &length) != nil {
+		return
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(length)*2); err != nil {
+		return
+	}
+	if binary.Read(r, binary.BigEndian, &length) != nil {
+		return
+	}
+	io.CopyN(io.Discard, r, int64(length))
+}
+
+// answers the server list ping of clients older than 1.7, the response is sent as a kick packet
+func handleLegacyPing(conn net.Conn, ip string) {
+	server.Logger.Debug("[TCP] ([%s] -> Server) Sent legacy ping", ip)
+	max := server.GetMaxPlayers()
+	online := len(server.Players.AsBase())
+	motd := server.GetMOTD(LEGACY_PING_PROTOCOL).ClearString()
+	// 1.4 and newer send 0xFE 0x01, beta 1.8 to 1.3 only send 0xFE
+	var response string
+	payload := make([]byte, 1)
+	conn.Socket.SetReadDeadline(time.Now().Add(LegacyPingTimeout))
+	if _, err := conn.Socket.Read(payload); err == nil && payload[0] == 0x01 {
+		// 1.6 follows with a MC|PingHost plugin message, it is read so closing the socket doesn't reset the connection
+		readLegacyPingHost(conn.Socket)
+		response = strings.Join([]string{"§1", fmt.Sprint(LEGACY_PING_PROTOCOL), server.GetVersionName(LEGACY_PING_PROTOCOL), motd, fmt.Sprint(online), fmt.Sprint(max)}, "\x00")
+	} else {
+		response = fmt.Sprintf("%s§%d§%d", strings.ReplaceAll(motd, "§", ""), online, max)
+	}
+	encoded := utf16.Encode([]rune(response))
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0xFF)
+	binary.Write(buf, binary.BigEndian, uint16(len(encoded)))
+	binary.Write(buf, binary.BigEndian, encoded)
+	conn.Socket.Write(buf.Bytes())
+	server.Logger.Debug("[TCP] (Server -> [%s]) Sent legacy ping response", ip)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the longest possible v1 header, "PROXY TCP6 " with two full ipv6 addresses and ports
+	ProxyProtocolV1MaxLength = 107
+	PROXY_PROTOCOL_LOCAL     = 0x20
+	PROXY_PROTOCOL_PROXY     = 0x21
+	PROXY_PROTOCOL_TCP4      = 0x11
+	PROXY_PROTOCOL_TCP6      = 0x21
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// proxiedConn reports the client address from the PROXY protocol header instead of the proxy's
+type proxiedConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (conn *proxiedConn) RemoteAddr() net.Addr {
+	return conn.remote
+}
+
+// reads a PROXY protocol v1 or v2 header byte by byte so nothing after it is consumed,
+// returns a nil address if the header doesn't carry one, like the health checks of v2
+func ReadProxyHeader(r io.Reader) (net.Addr, error) {
+	start := make([]byte, 5)
+	if _, err := io.ReadFull(r, start); err != nil {
+		return nil, err
+	}
+	if string(start) == "PROXY" {
+		return readProxyHeaderV1(r)
+	}
+	if !bytes.Equal(start, proxyProtocolV2Signature[:5]) {
+		return nil, ErrInvalidProxyHeader
+	}
+	return readProxyHeaderV2(r)
+}
+
+func readProxyHeaderV1(r io.Reader) (net.Addr, error) {
+	line := []byte("PROXY")
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= ProxyProtocolV1MaxLength {
+			return nil, ErrInvalidProxyHeader
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		line = append(line, b[0])
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidProxyHeader
+	}
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+func readProxyHeaderV2(r io.Reader) (net.Addr, error) {
+	header := make([]byte, 11)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:7], proxyProtocolV2Signature[5:]) {
+		return nil, ErrInvalidProxyHeader
+	}
+	command, family := header[7], header[8]
+	data := make([]byte, binary.BigEndian.Uint16(header[9:11]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	switch command {
+	case PROXY_PROTOCOL_LOCAL:
+		return nil, nil
+	case PROXY_PROTOCOL_PROXY:
+	default:
+		return nil, fmt.Errorf("unknown PROXY protocol command %#x", command)
+	}
+	var ip netip.Addr
+	var port uint16
+	switch family {
+	case PROXY_PROTOCOL_TCP4:
+		{
+			if len(data) < 12 {
+				return nil, ErrInvalidProxyHeader
+			}
+			ip = netip.AddrFrom4([4]byte(data[:4]))
+			port = binary.BigEndian.Uint16(data[8:10])
+		}
+	case PROXY_PROTOCOL_TCP6:
+		{
+			if len(data) < 36 {
+				return nil, ErrInvalidProxyHeader
+			}
+			ip = netip.AddrFrom16([16]byte(data[:16]))
+			port = binary.BigEndian.Uint16(data[32:34])
+		}
+	default:
+		return nil, nil
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func proxyHeaderV2(command, family byte, data []byte) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+	return append(header, data...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	v4 := []byte{203, 0, 113, 7, 10, 0, 0, 1, 0xd4, 0x31, 0x63, 0xdd}
+	v6 := make([]byte, 36)
+	v6[0], v6[1], v6[15] = 0x20, 0x01, 0x01
+	binary.BigEndian.PutUint16(v6[32:], 25565)
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+		err    bool
+	}{
+		{name: "v1 tcp4", header: []byte("PROXY TCP4 203.0.113.7 10.0.0.1 54321 25565\r\n"), want: "203.0.113.7:54321"},
+		{name: "v1 tcp6", header: []byte("PROXY TCP6 2001:db8::1 ::1 54321 25565\r\n"), want: "[2001:db8::1]:54321"},
+		{name: "v1 unknown", header: []byte("PROXY UNKNOWN\r\n")},
+		{name: "v1 longest", header: []byte("PROXY TCP6 ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff 65535 65535\r\n"), want: "[ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:65535"},
+		{name: "v1 oversized", header: []byte("PROXY TCP4 " + strings.Repeat("1", 100) + "\r\n"), err: true},
+		{name: "v1 truncated", header: []byte("PROXY TCP4 203.0.113.7 10.0.0.1 54321"), err: true},
+		{name: "v1 missing fields", header: []byte("PROXY TCP4 203.0.113.7 10.0.0.1\r\n"), err: true},
+		{name: "v1 bad address", header: []byte("PROXY TCP4 example.com 10.0.0.1 54321 25565\r\n"), err: true},
+		{name: "v1 bad port", header: []byte("PROXY TCP4 203.0.113.7 10.0.0.1 70000 25565\r\n"), err: true},
+		{name: "v1 bad protocol", header: []byte("PROXY UDP4 203.0.113.7 10.0.0.1 54321 25565\r\n"), err: true},
+		{name: "v2 tcp4", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, PROXY_PROTOCOL_TCP4, v4), want: "203.0.113.7:54321"},
+		{name: "v2 tcp6", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, PROXY_PROTOCOL_TCP6, v6), want: "[2001::1]:25565"},
+		{name: "v2 tlvs", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, PROXY_PROTOCOL_TCP4, append(v4, 0x04, 0x00, 0x01, 0xff)), want: "203.0.113.7:54321"},
+		{name: "v2 local", header: proxyHeaderV2(PROXY_PROTOCOL_LOCAL, 0, nil)},
+		{name: "v2 unspecified family", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, 0, nil)},
+		{name: "v2 unknown command", header: proxyHeaderV2(0x22, PROXY_PROTOCOL_TCP4, v4), err: true},
+		{name: "v2 short tcp4", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, PROXY_PROTOCOL_TCP4, v4[:8]), err: true},
+		{name: "v2 short tcp6", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, PROXY_PROTOCOL_TCP6, v6[:20]), err: true},
+		{name: "v2 truncated data", header: proxyHeaderV2(PROXY_PROTOCOL_PROXY, PROXY_PROTOCOL_TCP4, v4)[:20], err: true},
+		{name: "v2 truncated signature", header: proxyProtocolV2Signature[:8], err: true},
+		{name: "v2 bad signature", header: append([]byte("\r\n\r\n\x00\r\nQUIX\n"), make([]byte, 4)...), err: true},
+		{name: "handshake", header: []byte{0x10, 0x00, 0xfb, 0x05}, err: true},
+		{name: "empty", header: []byte{}, err: true},
+	}
+	for _, test := range tests {
+		// the header must be read exactly, the handshake after it is left for the connection
+		input := append([]byte{}, test.header...)
+		if !test.err {
+			input = append(input, "handshake"...)
+		}
+		r := bytes.NewReader(input)
+		addr, err := ReadProxyHeader(r)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.name, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var got string
+		if addr != nil {
+			got = addr.String()
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != "handshake" {
+			t.Errorf("%s: %q was left after the header", test.name, rest)
+		}
+	}
+}
+
+func TestReadProxyHeaderEOF(t *testing.T) {
+	_, err := ReadProxyHeader(bytes.NewReader([]byte("PROXY TCP4 1.2.3.4")))
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated header returned %v, want EOF", err)
+	}
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	proxies := []string{"127.0.0.1", "10.0.0.0/8", "::1", "not a proxy"}
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "10.20.30.40", want: true},
+		{addr: "::1", want: true},
+		{addr: "127.0.0.2", want: false},
+		{addr: "11.0.0.1", want: false},
+		{addr: "", want: false},
+	}
+	for _, test := range tests {
+		if got := IsTrustedProxy(test.addr, proxies); got != test.want {
+			t.Errorf("IsTrustedProxy(%q) = %t, want %t", test.addr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	QUERY_TYPE_STAT      = 0
+	QUERY_TYPE_HANDSHAKE = 9
+)
+
+const QueryTokenLifetime = 30 * time.Second
+
+var queryMagic = []byte{0xFE, 0xFD}
+
+type queryToken struct {
+	Token   int32
+	Created time.Time
+}
+
+// QueryServer answers GameSpy4 query requests, challenge tokens are kept per address
+type QueryServer struct {
+	conn   *net.UDPConn
+	tokens map[string]queryToken
+	sync.Mutex
+}
+
+func QueryListen() {
+	if !server.Config.Query.Enable {
+		return
+	}
+	addr, err := net.ResolveUDPAddr("udp", server.Config.ServerIP+":"+fmt.Sprint(server.Config.Query.ServerPort))
+	if err != nil {
+		server.Logger.Error("[Query] Failed to listen: %s", err.Error())
+		return
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		server.Logger.Error("[Query] Failed to listen: %s", err.Error())
+		return
+	}
+	server.Logger.Info("[Query] Listening on %s", addr)
+	query := &QueryServer{conn: conn, tokens: make(map[string]queryToken)}
+	go query.Serve()
+}
+
+func (query *QueryServer) Serve() {
+	buf := make([]byte, 1460)
+	lastClean := time.Now()
+	for {
+		n, addr, err := query.conn.ReadFromUDP(buf)
+		if err != nil {
+			continue
+		}
+		if time.Since(lastClean) > QueryTokenLifetime {
+			query.cleanTokens()
+			lastClean = time.Now()
+		}
+		if response := query.HandlePacket(buf[:n], addr.IP.String()); response != nil {
+			query.conn.WriteToUDP(response, addr)
+		}
+	}
+}
+
+func (query *QueryServer) cleanTokens() {
+	query.Lock()
+	defer query.Unlock()
+	for addr, token := range query.tokens {
+		if time.Since(token.Created) > QueryTokenLifetime {
+			delete(query.tokens, addr)
+		}
+	}
+}
+
+func (query *QueryServer) checkToken(addr string, token int32) bool {
+	query.Lock()
+	defer query.Unlock()
+	t, ok := query.tokens[addr]
+	return ok && t.Token == token && time.Since(t.Created) <= QueryTokenLifetime
+}
+
+// returns the response to a query packet, or nil if the packet should be ignored
+func (query *QueryServer) HandlePacket(data []byte, addr string) []byte {
+	if len(data) < 7 || !bytes.Equal(data[:2], queryMagic) {
+		return nil
+	}
+	packetType := data[2]
+	session := int32(binary.BigEndian.Uint32(data[3:7])) & 0x0F0F0F0F
+	response := new(bytes.Buffer)
+	response.WriteByte(packetType)
+	binary.Write(response, binary.BigEndian, session)
+	switch packetType {
+	case QUERY_TYPE_HANDSHAKE:
+		{
+			token := rand.Int31()
+			query.Lock()
+			query.tokens[addr] = queryToken{Token: token, Created: time.Now()}
+			query.Unlock()
+			writeQueryString(response, strconv.Itoa(int(token)))
+		}
+	case QUERY_TYPE_STAT:
+		{
+			if len(data) < 11 || !query.checkToken(addr, int32(binary.BigEndian.Uint32(data[7:11]))) {
+				return nil
+			}
+			if len(data) >= 15 {
+				writeFullStat(response)
+			} else {
+				writeBasicStat(response)
+			}
+		}
+	default:
+		return nil
+	}
+	return response.Bytes()
+}
+
+func writeQueryString(buf *bytes.Buffer, str string) {
+	buf.WriteString(str)
+	buf.WriteByte(0)
+}
+
+func queryMOTD() string {
+	return server.GetMOTD(PROTOCOL_1_19_4).ClearString()
+}
+
+func writeBasicStat(buf *bytes.Buffer) {
+	writeQueryString(buf, queryMOTD())
+	writeQueryString(buf, "SMP")
+	writeQueryString(buf, server.Level.Data.LevelName)
+	writeQueryString(buf, fmt.Sprint(len(server.Players.AsBase())))
+	writeQueryString(buf, fmt.Sprint(server.GetMaxPlayers()))
+	binary.Write(buf, binary.LittleEndian, uint16(server.Config.ServerPort))
+	writeQueryString(buf, server.Config.ServerIP)
+}
+
+func writeFullStat(buf *bytes.Buffer) {
+	players := server.Players.AsBase()
+	plugins := "Dynamite " + MinecraftVersion
+	if len(server.Plugins) > 0 {
+		plugins += ": " + strings.Join(server.Plugins, "; ")
+	}
+	buf.Write([]byte("splitnum\x00\x80\x00"))
+	for _, kv := range [][2]string{
+		{"hostname", queryMOTD()},
+		{"gametype", "SMP"},
+		{"game_id", "MINECRAFT"},
+		{"version", MinecraftVersion},
+		{"plugins", plugins},
+		{"map", server.Level.Data.LevelName},
+		{"numplayers", fmt.Sprint(len(players))},
+		{"maxplayers", fmt.Sprint(server.GetMaxPlayers())},
+		{"hostport", fmt.Sprint(server.Config.ServerPort)},
+		{"hostip", server.Config.ServerIP},
+	} {
+		writeQueryString(buf, kv[0])
+		writeQueryString(buf, kv[1])
+	}
+	buf.WriteByte(0)
+	buf.Write([]byte("\x01player_\x00\x00"))
+	for _, player := range players {
+		writeQueryString(buf, player.Name)
+	}
+	buf.WriteByte(0)
+}
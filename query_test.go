@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func queryPacket(packetType byte, session uint32, payload ...byte) []byte {
+	packet := append([]byte{}, queryMagic...)
+	packet = append(packet, packetType)
+	packet = binary.BigEndian.AppendUint32(packet, session)
+	return append(packet, payload...)
+}
+
+// returns the challenge token of a handshake response
+func handshakeToken(t *testing.T, response []byte) []byte {
+	token, err := strconv.Atoi(string(bytes.TrimSuffix(response[5:], []byte{0})))
+	if err != nil {
+		t.Fatalf("invalid handshake response %q", response)
+	}
+	return binary.BigEndian.AppendUint32(nil, uint32(token))
+}
+
+func TestQueryHandlePacket(t *testing.T) {
+	query := &QueryServer{tokens: make(map[string]queryToken)}
+	handshake := query.HandlePacket(queryPacket(QUERY_TYPE_HANDSHAKE, 0xFFFFFFFF), "203.0.113.7")
+	if handshake == nil || handshake[0] != QUERY_TYPE_HANDSHAKE {
+		t.Fatalf("invalid handshake response %q", handshake)
+	}
+	if session := binary.BigEndian.Uint32(handshake[1:5]); session != 0x0F0F0F0F {
+		t.Errorf("session id %#x wasn't masked", session)
+	}
+	token := handshakeToken(t, handshake)
+	wrong := binary.BigEndian.AppendUint32(nil, binary.BigEndian.Uint32(token)+1)
+
+	tests := []struct {
+		name   string
+		packet []byte
+		addr   string
+		want   string
+	}{
+		{name: "basic stat", packet: queryPacket(QUERY_TYPE_STAT, 1, token...), addr: "203.0.113.7", want: "SMP"},
+		{name: "full stat", packet: queryPacket(QUERY_TYPE_STAT, 1, append(token, 0, 0, 0, 0)...), addr: "203.0.113.7", want: "splitnum"},
+		{name: "wrong token", packet: queryPacket(QUERY_TYPE_STAT, 1, wrong...), addr: "203.0.113.7"},
+		{name: "other address", packet: queryPacket(QUERY_TYPE_STAT, 1, token...), addr: "203.0.113.8"},
+		{name: "missing token", packet: queryPacket(QUERY_TYPE_STAT, 1, token[:3]...), addr: "203.0.113.7"},
+		{name: "unknown type", packet: queryPacket(5, 1, token...), addr: "203.0.113.7"},
+		{name: "bad magic", packet: append([]byte{0xFE, 0xFE}, queryPacket(QUERY_TYPE_STAT, 1, token...)[2:]...), addr: "203.0.113.7"},
+		{name: "short", packet: queryMagic, addr: "203.0.113.7"},
+	}
+	for _, test := range tests {
+		response := query.HandlePacket(test.packet, test.addr)
+		if test.want == "" {
+			if response != nil {
+				t.Errorf("%s: got %q, want no response", test.name, response)
+			}
+			continue
+		}
+		if response == nil || response[0] != QUERY_TYPE_STAT || !bytes.Contains(response, []byte(test.want)) {
+			t.Errorf("%s: got %q, want a response with %q", test.name, response, test.want)
+		}
+	}
+
+	// tokens stop working after their lifetime
+	query.tokens["203.0.113.7"] = queryToken{Token: int32(binary.BigEndian.Uint32(token)), Created: time.Now().Add(-QueryTokenLifetime - time.Second)}
+	if response := query.HandlePacket(queryPacket(QUERY_TYPE_STAT, 1, token...), "203.0.113.7"); response != nil {
+		t.Errorf("expired token got %q", response)
+	}
+}
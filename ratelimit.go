@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type rateLimitEntry struct {
+	Count int
+	Start time.Time
+}
+
+// RateLimiter counts events per key (usually an ip) in fixed windows
+type RateLimiter struct {
+	sync.Mutex
+	Window    time.Duration
+	entries   map[string]*rateLimitEntry
+	lastPrune time.Time
+}
+
+func NewRateLimiter(window time.Duration) *RateLimiter {
+	return &RateLimiter{Window: window, entries: make(map[string]*rateLimitEntry), lastPrune: time.Now()}
+}
+
+// counts an event for key and reports whether it is within limit, a limit of 0 or less disables it
+func (limiter *RateLimiter) Allow(key string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+	limiter.Lock()
+	defer limiter.Unlock()
+	now := time.Now()
+	if now.Sub(limiter.lastPrune) > limiter.Window {
+		for k, entry := range limiter.entries {
+			if now.Sub(entry.Start) > limiter.Window {
+				delete(limiter.entries, k)
+			}
+		}
+		limiter.lastPrune = now
+	}
+	entry := limiter.entries[key]
+	if entry == nil || now.Sub(entry.Start) > limiter.Window {
+		entry = &rateLimitEntry{Start: now}
+		limiter.entries[key] = entry
+	}
+	entry.Count++
+	return entry.Count <= limit
+}
+
+// reports whether key already reached limit in the current window without counting an event
+func (limiter *RateLimiter) Exceeded(key string, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	limiter.Lock()
+	defer limiter.Unlock()
+	entry := limiter.entries[key]
+	return entry != nil && time.Since(entry.Start) <= limiter.Window && entry.Count >= limit
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Tnze/go-mc/net"
+)
+
+const (
+	RCONLoginTimeout = 10 * time.Second
+	// responses longer than this are split over multiple packets like vanilla does
+	RCONMaxResponseSize = 4096
+	// how long an ip is locked out after too many failed logins
+	RCONLockout = 10 * time.Minute
+)
+
+var rconLoginLimiter = NewRateLimiter(RCONLockout)
+
+func RCONListen() {
+	config := server.Config.RCON
+	if !config.Enable {
+		return
+	}
+	if config.Password == "" {
+		server.Logger.Warn("[RCON] No password is set, RCON will not be started")
+		return
+	}
+	listener, err := net.ListenRCON(config.ServerIP + ":" + fmt.Sprint(config.ServerPort))
+	if err != nil {
+		server.Logger.Error("[RCON] Failed to listen: %s", err.Error())
+		return
+	}
+	server.Logger.Info("[RCON] Listening on %s:%d", config.ServerIP, config.ServerPort)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				continue
+			}
+			go HandleRCONConnection(conn.(*net.RCONConn))
+		}
+	}()
+}
+
+// rconLimiter allows up to limit commands in every second, a limit of 0 or less disables it
+type rconLimiter struct {
+	limit  int
+	count  int
+	window time.Time
+}
+
+func (limiter *rconLimiter) Allow() bool {
+	if limiter.limit <= 0 {
+		return true
+	}
+	if now := time.Now(); now.Sub(limiter.window) >= time.Second {
+		limiter.window = now
+		limiter.count = 0
+	}
+	limiter.count++
+	return limiter.count <= limiter.limit
+}
+
+func HandleRCONConnection(conn *net.RCONConn) {
+	defer conn.Close()
+	addr := conn.RemoteAddr().String()
+	ip := RemoteAddress(addr)
+	attempts := server.Config.RCON.LoginAttempts
+	if rconLoginLimiter.Exceeded(ip, attempts) {
+		server.Logger.Warn("[RCON] %s is locked out after too many failed logins", addr)
+		return
+	}
+	conn.SetDeadline(time.Now().Add(RCONLoginTimeout))
+	if err := conn.AcceptLogin(server.Config.RCON.Password); err != nil {
+		rconLoginLimiter.Allow(ip, attempts)
+		server.Logger.Warn("[RCON] %s failed to log in: %s", addr, err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	server.Logger.Info("[RCON] %s logged in", addr)
+	limiter := rconLimiter{limit: server.Config.RCON.RateLimit}
+	for {
+		command, err := conn.AcceptCmd()
+		if err != nil {
+			server.Logger.Info("[RCON] %s disconnected", addr)
+			return
+		}
+		command = strings.TrimPrefix(strings.TrimSpace(command), "/")
+		if !limiter.Allow() {
+			server.Logger.Warn("[RCON] %s is sending commands too quickly, ignoring: %s", addr, command)
+			conn.RespCmd("Too many commands, please slow down")
+			continue
+		}
+		server.Logger.Info("[RCON] %s issued server command: %s", addr, command)
+		response := server.Command("rcon", command).ClearString()
+		for {
+			part := response
+			if len(part) > RCONMaxResponseSize {
+				// cut on a rune boundary so multi-byte characters aren't split
+				end := RCONMaxResponseSize
+				for end > 0 && !utf8.RuneStart(part[end]) {
+					end--
+				}
+				part = part[:end]
+			}
+			if err := conn.RespCmd(part); err != nil {
+				return
+			}
+			response = response[len(part):]
+			if response == "" {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Tnze/go-mc/chat"
+	"github.com/Tnze/go-mc/chat/sign"
+	"github.com/Tnze/go-mc/data/packetid"
+	pk "github.com/Tnze/go-mc/net/packet"
+	"github.com/Tnze/go-mc/yggdrasil/user"
+	"github.com/google/uuid"
+)
+
+const (
+	SECURE_CHAT_ENFORCE = "enforce"
+	SECURE_CHAT_ALLOW   = "allow"
+	SECURE_CHAT_DISABLE = "disable"
+)
+
+const (
+	// how many of the last messages a client can acknowledge
+	LastSeenMessageCount = 20
+	// clients hide messages older than this, like vanilla the server only warns about them
+	ChatMessageLifetime = 5 * time.Minute
+	// how many signatures a client caches so they can be sent as ids
+	SignatureCacheSize = 128
+	// like vanilla clients are disconnected when more messages than this aren't acknowledged
+	MaxPendingChatMessages = 4096
+)
+
+// the key Mojang signs profile public keys with, bundled so they can be verified without the session servers
+//
+//go:embed yggdrasil_session_pubkey.der
+var mojangPublicKeyData []byte
+
+var mojangPublicKey = parseMojangPublicKey()
+
+func parseMojangPublicKey() *rsa.PublicKey {
+	key, err := x509.ParsePKIXPublicKey(mojangPublicKeyData)
+	if err != nil {
+		panic(err)
+	}
+	return key.(*rsa.PublicKey)
+}
+
+// ChatMessage is a chat message a player sent, Signature is nil if it isn't signed or secure chat is disabled
+type ChatMessage struct {
+	Body sign.MessageBody
+	// the text shown to players, filters may change it while the signed body stays what the player typed
+	Content   string
+	Signature *sign.Signature
+	// the index of the message in the sender's chain
+	Index int
+	// the id moderators delete the message with, 0 if it can't be deleted
+	ID int
+	// the chat channel the message is sent in, "" if there are no channels
+	Channel string
+	// set by PlayerChatFilter listeners to stop the message from being sent
+	Cancelled bool
+}
+
+type lastSeenEntry struct {
+	Signature *sign.Signature
+	Pending   bool
+}
+
+// ChatSession holds the chat session a client announced and what is needed to validate its messages
+type ChatSession struct {
+	sync.Mutex
+	// nil until the client sends a valid session
+	Session *sign.Session
+	// the index the next signed message of the client must have
+	Index         int
+	LastTimestamp time.Time
+	// the signed messages sent to the client that it can still acknowledge, the oldest first
+	tracked     []*lastSeenEntry
+	lastPending *sign.Signature
+	// mirrors the signature cache of the client, which it fills with the signed messages it receives
+	cache [SignatureCacheSize]*sign.Signature
+}
+
+func NewChatSession() *ChatSession {
+	return &ChatSession{tracked: make([]*lastSeenEntry, LastSeenMessageCount)}
+}
+
+type argumentSignature struct {
+	Name      pk.String
+	Signature sign.Signature
+}
+
+func (argument *argumentSignature) ReadFrom(r io.Reader) (int64, error) {
+	return pk.Tuple{&argument.Name, &argument.Signature}.ReadFrom(r)
+}
+
+// secure chat needs the real uuid of players, so it's only available in online mode or behind a forwarding proxy
+func SecureChatAvailable() bool {
+	return server.Config.Chat.SecureChat != SECURE_CHAT_DISABLE && (server.Config.Online || ForwardingEnabled())
+}
+
+func EnforcesSecureChat() bool {
+	return server.Config.Chat.SecureChat == SECURE_CHAT_ENFORCE && SecureChatAvailable()
+}
+
+// checks the signature Mojang made over the uuid of the player, the expiry and the key
+func VerifyProfileKey(id uuid.UUID, expiresAt int64, key []byte, signature []byte) bool {
+	payload := make([]byte, 24, 24+len(key))
+	copy(payload, id[:])
+	binary.BigEndian.PutUint64(payload[16:], uint64(expiresAt))
+	payload = append(payload, key...)
+	hash := sha1.Sum(payload)
+	return rsa.VerifyPKCS1v15(mojangPublicKey, crypto.SHA1, hash[:], signature) == nil
+}
+
+// checks the signature of a message at index in the chain of sender's session
+func VerifyChatSignature(key *rsa.PublicKey, sender uuid.UUID, session uuid.UUID, index int, body sign.MessageBody, signature *sign.Signature) bool {
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, int32(1))
+	hash.Write(sender[:])
+	hash.Write(session[:])
+	binary.Write(hash, binary.BigEndian, int32(index))
+	binary.Write(hash, binary.BigEndian, body.Salt)
+	binary.Write(hash, binary.BigEndian, body.Timestamp.Unix())
+	content := []byte(body.PlainMsg)
+	binary.Write(hash, binary.BigEndian, int32(len(content)))
+	hash.Write(content)
+	binary.Write(hash, binary.BigEndian, int32(len(body.LastSeen)))
+	for _, seen := range body.LastSeen {
+		hash.Write(seen[:])
+	}
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash.Sum(nil), signature[:]) == nil
+}
+
+// returns the session of the client, or nil
+func (state *ChatSession) Current() *sign.Session {
+	state.Lock()
+	defer state.Unlock()
+	return state.Session
+}
+
+// tracks a signed message sent to the client so it can be acknowledged and its signatures are cached,
+// returns false if the client has too many unacknowledged messages
+func (state *ChatSession) AddPending(message *ChatMessage) bool {
+	state.Lock()
+	defer state.Unlock()
+	state.push(append(append([]*sign.Signature{}, message.Body.LastSeen...), message.Signature))
+	if state.lastPending != nil && *state.lastPending == *message.Signature {
+		return true
+	}
+	if len(state.tracked) > MaxPendingChatMessages {
+		return false
+	}
+	state.tracked = append(state.tracked, &lastSeenEntry{Signature: message.Signature, Pending: true})
+	state.lastPending = message.Signature
+	return true
+}
+
+// moves signatures to the front of the cache like the client does, the last one first
+func (state *ChatSession) push(signatures []*sign.Signature) {
+	pushed := make(map[sign.Signature]bool, len(signatures))
+	for _, signature := range signatures {
+		pushed[*signature] = true
+	}
+	for i := 0; len(signatures) > 0 && i < len(state.cache); i++ {
+		old := state.cache[i]
+		state.cache[i] = signatures[len(signatures)-1]
+		signatures = signatures[:len(signatures)-1]
+		if old != nil && !pushed[*old] {
+			signatures = append([]*sign.Signature{old}, signatures...)
+		}
+	}
+}
+
+// returns the id of signature in the cache of the client, or the full signature if it isn't cached
+func (state *ChatSession) PackSignature(signature *sign.Signature) sign.PackedSignature {
+	state.Lock()
+	defer state.Unlock()
+	return state.pack(signature)
+}
+
+func (state *ChatSession) pack(signature *sign.Signature) sign.PackedSignature {
+	for i, cached := range state.cache {
+		if cached != nil && *cached == *signature {
+			return sign.PackedSignature{ID: int32(i)}
+		}
+	}
+	return sign.PackedSignature{ID: -1, Signature: signature}
+}
+
+// packs the last seen signatures of a message for the client
+func (state *ChatSession) PackLastSeen(signatures []*sign.Signature) []sign.PackedSignature {
+	state.Lock()
+	defer state.Unlock()
+	packed := make([]sign.PackedSignature, len(signatures))
+	for i, signature := range signatures {
+		packed[i] = state.pack(signature)
+	}
+	return packed
+}
+
+// drops the offset oldest messages, which the client no longer tracks
+func (state *ChatSession) applyOffset(offset int) bool {
+	if offset < 0 || offset > len(state.tracked)-LastSeenMessageCount {
+		return false
+	}
+	state.tracked = state.tracked[offset:]
+	return true
+}
+
+// applies the last seen update of a message and returns the signatures it acknowledges
+func (state *ChatSession) applyUpdate(update sign.HistoryUpdate) ([]*sign.Signature, bool) {
+	if !state.applyOffset(int(update.Offset)) {
+		return nil, false
+	}
+	for i := LastSeenMessageCount; i < update.Acknowledged.Len(); i++ {
+		if update.Acknowledged.Get(i) {
+			return nil, false
+		}
+	}
+	var signatures []*sign.Signature
+	for i := 0; i < LastSeenMessageCount; i++ {
+		entry := state.tracked[i]
+		if update.Acknowledged.Get(i) {
+			if entry == nil {
+				return nil, false
+			}
+			entry.Pending = false
+			signatures = append(signatures, entry.Signature)
+		} else {
+			if entry != nil && !entry.Pending {
+				return nil, false
+			}
+			state.tracked[i] = nil
+		}
+	}
+	return signatures, true
+}
+
+// checks what chat messages and commands have in common, returns the acknowledged signatures or a disconnect message
+func (state *ChatSession) accept(player *Player, timestamp time.Time, lastSeen sign.HistoryUpdate) ([]*sign.Signature, string) {
+	if timestamp.Before(state.LastTimestamp) {
+		return nil, server.Config.Messages.OutOfOrderChat
+	}
+	state.LastTimestamp = timestamp
+	if time.Since(timestamp) > ChatMessageLifetime {
+		server.Logger.Warn("[%s] Player %s (%s) sent an expired chat message", player.IP, player.Name, player.UUID.String)
+	}
+	if !SecureChatAvailable() {
+		return nil, ""
+	}
+	signatures, ok := state.applyUpdate(lastSeen)
+	if !ok {
+		return nil, server.Config.Messages.ChatValidationFailed
+	}
+	return signatures, ""
+}
+
+// reads and validates a chat packet, returns nil and a disconnect message if the client should be kicked
+func (player *Player) ReadChatMessage(packet pk.Packet) (*ChatMessage, string) {
+	var (
+		message   pk.String
+		timestamp pk.Long
+		salt      pk.Long
+		signature pk.Option[sign.Signature, *sign.Signature]
+		lastSeen  = sign.HistoryUpdate{Acknowledged: pk.NewFixedBitSet(LastSeenMessageCount)}
+	)
+	if packet.Scan(&message, &timestamp, &salt, &signature, &lastSeen) != nil {
+		return nil, server.Config.Messages.ChatValidationFailed
+	}
+	state := player.Chat
+	state.Lock()
+	defer state.Unlock()
+	signatures, reason := state.accept(player, time.UnixMilli(int64(timestamp)), lastSeen)
+	if reason != "" {
+		return nil, reason
+	}
+	chatMessage := &ChatMessage{Body: sign.MessageBody{
+		PlainMsg:  string(message),
+		Timestamp: time.UnixMilli(int64(timestamp)),
+		Salt:      int64(salt),
+		LastSeen:  signatures,
+	}, Content: string(message)}
+	if !SecureChatAvailable() || !bool(signature.Has) || state.Session == nil {
+		if EnforcesSecureChat() {
+			return nil, server.Config.Messages.UnsignedChat
+		}
+		return chatMessage, ""
+	}
+	if state.Session.PublicKey.ExpiresAt.Before(time.Now()) {
+		return nil, server.Config.Messages.ExpiredPublicKey
+	}
+	if !VerifyChatSignature(state.Session.PublicKey.PubKey, uuid.UUID(player.UUID.Binary), state.Session.SessionID, state.Index, chatMessage.Body, &signature.Val) {
+		return nil, server.Config.Messages.UnsignedChat
+	}
+	chatMessage.Signature = &signature.Val
+	chatMessage.Index = state.Index
+	state.Index++
+	return chatMessage, ""
+}
+
+// reads a command packet, the signed arguments of commands aren't verified but still advance the chain
+func (player *Player) ReadChatCommand(packet pk.Packet) (string, string) {
+	var (
+		command   pk.String
+		timestamp pk.Long
+		salt      pk.Long
+		arguments []argumentSignature
+		lastSeen  = sign.HistoryUpdate{Acknowledged: pk.NewFixedBitSet(LastSeenMessageCount)}
+	)
+	if packet.Scan(&command, &timestamp, &salt, pk.Array(&arguments), &lastSeen) != nil {
+		return "", server.Config.Messages.ChatValidationFailed
+	}
+	state := player.Chat
+	state.Lock()
+	defer state.Unlock()
+	if _, reason := state.accept(player, time.UnixMilli(int64(timestamp)), lastSeen); reason != "" {
+		return "", reason
+	}
+	if state.Session != nil {
+		state.Index += len(arguments)
+	}
+	return string(command), ""
+}
+
+// handles the client acknowledging messages without sending one
+func (player *Player) AcknowledgeChat(packet pk.Packet) string {
+	var offset pk.VarInt
+	if packet.Scan(&offset) != nil {
+		return server.Config.Messages.ChatValidationFailed
+	}
+	if !SecureChatAvailable() {
+		return ""
+	}
+	player.Chat.Lock()
+	defer player.Chat.Unlock()
+	if !player.Chat.applyOffset(int(offset)) {
+		return server.Config.Messages.ChatValidationFailed
+	}
+	return ""
+}
+
+// verifies the profile public key of the client and starts a new chain with it
+func (player *Player) UpdateChatSession(packet pk.Packet) string {
+	if !SecureChatAvailable() {
+		return ""
+	}
+	var (
+		sessionID    pk.UUID
+		expiresAt    pk.Long
+		key          pk.ByteArray
+		keySignature pk.ByteArray
+	)
+	if packet.Scan(&sessionID, &expiresAt, &key, &keySignature) != nil {
+		return server.Config.Messages.InvalidPublicKey
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(key)
+	if err != nil {
+		return server.Config.Messages.InvalidPublicKey
+	}
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return server.Config.Messages.InvalidPublicKey
+	}
+	if time.UnixMilli(int64(expiresAt)).Before(time.Now()) {
+		return server.Config.Messages.ExpiredPublicKey
+	}
+	if !VerifyProfileKey(uuid.UUID(player.UUID.Binary), int64(expiresAt), key, keySignature) {
+		return server.Config.Messages.InvalidPublicKey
+	}
+	session := &sign.Session{
+		SessionID: uuid.UUID(sessionID),
+		PublicKey: user.PublicKey{
+			ExpiresAt: time.UnixMilli(int64(expiresAt)),
+			PubKey:    rsaKey,
+			Signature: keySignature,
+		},
+	}
+	player.Chat.Lock()
+	if current := player.Chat.Session; current != nil && current.SessionID == session.SessionID && rsaKey.Equal(current.PublicKey.PubKey) {
+		player.Chat.Unlock()
+		return ""
+	}
+	player.Chat.Session = session
+	player.Chat.Index = 0
+	player.Chat.Unlock()
+	server.Logger.Debug("[%s] Player %s (%s) started chat session %s", player.IP, player.Name, player.UUID.String, session.SessionID)
+	server.Playerlist.InitializeChat(player, session)
+	return ""
+}
+
+func (player *Player) KickForChat(reason string) {
+	server.Logger.Info("[%s] Player %s (%s) was kicked. reason: %s", player.IP, player.Name, player.UUID.String, reason)
+	server.Kick(player.UUID.String, chat.Text(reason))
+}
+
+// shares the chat session of player so other clients can verify its messages
+func (playerlist Playerlist) InitializeChat(player *Player, session *sign.Session) {
+	server.BroadcastPacket(pk.Marshal(packetid.ClientboundPlayerInfoUpdate,
+		NewPlayerInfoAction(PlayerInfoInitializeChat),
+		pk.VarInt(1),
+		player.UUID.Binary,
+		pk.Boolean(true),
+		session,
+	))
+}
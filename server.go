@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"dynamite/logger"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"image"
@@ -160,6 +161,103 @@ func (data PlayerData) WriteTo(w io.Writer) (int64, error) {
 	}.WriteTo(w)
 }
 
+var gamemodes = []string{"survival", "creative", "adventure", "spectator"}
+
+// parses a gamemode name (creative) or id (1)
+func ParseGamemode(str string) (int, bool) {
+	str = strings.ToLower(str)
+	for i, gamemode := range gamemodes {
+		if str == gamemode || str == fmt.Sprint(i) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func GamemodeName(gamemode int32) string {
+	if gamemode < 0 || int(gamemode) >= len(gamemodes) {
+		return "unknown"
+	}
+	return gamemodes[gamemode]
+}
+
+func (player *Player) SendAbilities() {
+	var flags byte
+	switch player.Data.PlayerGameType {
+	case GAMEMODE_CREATIVE:
+		flags = 0x01 | 0x04 | 0x08
+	case GAMEMODE_SPECTATOR:
+		flags = 0x01 | 0x02 | 0x04
+	}
+	player.Connection.WritePacket(pk.Marshal(packetid.ClientboundPlayerAbilities,
+		pk.Byte(flags),
+		pk.Float(0.05), // flying speed
+		pk.Float(0.1),  // field of view modifier
+	))
+}
+
+func (player *Player) SetGamemode(gamemode int) {
+	player.Data.PlayerGameType = int32(gamemode)
+	player.Connection.WritePacket(pk.Marshal(
+		packetid.ClientboundGameEvent,
+		pk.UnsignedByte(3),
+		pk.Float(gamemode),
+	))
+	player.SendAbilities()
+	server.Playerlist.UpdateGameMode(player)
+	player.Data.Save(player.UUID.String)
+}
+
+// sends a keep alive every KeepAliveInterval and kicks the player if one isn't answered within KeepAliveTimeout
+func (player *Player) KeepAliveLoop(done chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			player.Lock()
+			id := player.KeepAliveID
+			if id != 0 {
+				player.Unlock()
+				if now.Sub(time.UnixMilli(id)) >= KeepAliveTimeout {
+					server.Logger.Info("[%s] Player %s (%s) timed out", player.IP, player.Name, player.UUID.String)
+					server.Kick(player.UUID.String, chat.Text(server.Config.Messages.TimedOut))
+					return
+				}
+				continue
+			}
+			if !player.LastKeepAlive.IsZero() && now.Sub(player.LastKeepAlive) < KeepAliveInterval {
+				player.Unlock()
+				continue
+			}
+			player.KeepAliveID = now.UnixMilli()
+			player.LastKeepAlive = now
+			id = player.KeepAliveID
+			player.Unlock()
+			// written without holding the lock, a client that stops reading only blocks this loop
+			player.Connection.WritePacket(pk.Marshal(packetid.ClientboundKeepAlive, pk.Long(id)))
+			server.Logger.Debug("[TCP] (Server -> [%s]) Sent KeepAlive packet", player.IP)
+		}
+	}
+}
+
+// handles a keep alive response, returns false if it doesn't answer the pending keep alive
+func (player *Player) AnswerKeepAlive(id int64) bool {
+	player.Lock()
+	if id != player.KeepAliveID {
+		player.Unlock()
+		return false
+	}
+	player.KeepAliveID = 0
+	latency := int(time.Since(time.UnixMilli(id)).Milliseconds())
+	player.Latency = (player.Latency*3 + latency) / 4
+	player.Unlock()
+	server.Playerlist.UpdateLatency(player)
+	return true
+}
+
 func (data PlayerData) Save(playerId string) {
 	server.WritePlayerData(playerId, data)
 }
@@ -208,8 +306,11 @@ func (graph CommandGraph) WriteTo(w io.Writer) (int64, error) {
 	var rootChildren []int32
 	var nodes []Node
 	i := 1
-	commands := server.Commands
-	for _, command := range commands {
+	commands := make(map[string]Command)
+	for name, command := range server.Commands {
+		commands[name] = command
+	}
+	for _, command := range server.Commands {
 		for _, alias := range command.Aliases {
 			cmd := command
 			cmd.Aliases = []string{}
@@ -321,7 +422,7 @@ func (emitter Events) RemoveListener(key string, index int) {
 func (emitter Events) RemoveAllListeners(key string) {
 	delete(emitter._Events, key)
 }
-func (players PlayersC) IsOP(id string) (bool, PlayerBase) {
+func (players PlayersC) IsOP(id string) (bool, OPEntry) {
 	players.Lock()
 	defer players.Unlock()
 	for _, op := range players.OPs {
@@ -329,7 +430,7 @@ func (players PlayersC) IsOP(id string) (bool, PlayerBase) {
 			return true, op
 		}
 	}
-	return false, PlayerBase{}
+	return false, OPEntry{}
 }
 
 func (emitter Events) Emit(key string, data ...interface{}) {
@@ -461,20 +562,26 @@ func (world World) PutChunk(pos [2]int32, c *level.Chunk) (err error) {
 func (server *Server) Init() {
 	server.Players.Mutex = &sync.Mutex{}
 	server.Players.Whitelist = LoadPlayerList("whitelist.json")
-	server.Players.OPs = LoadPlayerList("ops.json")
-	server.Players.BannedPlayers = LoadPlayerList("banned_players.json")
-	server.Players.BannedIPs = LoadIPBans()
+	server.Players.OPs = LoadOPList("ops.json")
+	server.Players.LoadBans()
+	server.Players.LoadMutes()
 	server.Worlds = make(map[string]World)
 	server.LoadAllPlugins()
 	os.MkdirAll("permissions/groups", 0755)
 	os.MkdirAll("permissions/players", 0755)
-	os.WriteFile("permissions/groups/default.json", []byte(`{"display_name":"default","permissions":{"server.chat":true}}`), 0755)
+	os.WriteFile("permissions/groups/default.json", []byte(`{"display_name":"default","permissions":{"server.chat":true,"server.command.msg":true,"server.command.me":true,"server.command.channel":true}}`), 0755)
+	CreateOPGroups()
 	server.Logger.Debug("Loaded player info")
 	if !server.Config.Online && !logger.HasArg("-no_offline_warn") {
 		server.Logger.Warn("Offline mode is insecure. You can disable this message using -no_offline_warn")
 	}
+	if server.Config.Forwarding.Mode == FORWARDING_BUNGEECORD {
+		server.Logger.Warn("BungeeCord forwarding can't be verified, players are trusted from %s only. Make sure nothing else can reach the server port or use Velocity forwarding", strings.Join(server.Config.Forwarding.TrustedProxies, ", "))
+	}
 	server.ParseWorldData()
 	TCPListen()
+	RCONListen()
+	QueryListen()
 	CreateEvents()
 }
 
@@ -516,7 +623,7 @@ func (server Server) GetGroup(playerId string) (string, string, string) {
 	return group.DisplayName, group.Prefix, group.Suffix
 }
 
-func (server Server) LoadAllPlugins() {
+func (server *Server) LoadAllPlugins() {
 	os.Mkdir("plugins", 0755)
 	plugins, _ := os.ReadDir("plugins")
 	for _, plugin := range plugins {
@@ -524,15 +631,56 @@ func (server Server) LoadAllPlugins() {
 	}
 }
 
-func (server Server) LoadPlugin(fileName string) {
+const (
+	// how many messages can wait for a plugin before new ones are dropped
+	PluginQueueSize = 256
+	// how long a login waits for a plugin to answer a login query
+	PluginLoginQueryTimeout = 5 * time.Second
+)
+
+type pluginLoginResult struct {
+	allowed bool
+	reason  string
+}
+
+func (server *Server) LoadPlugin(fileName string) {
 	server.Logger.Info("Loading plugin %s", fileName)
 	path, err := exec.LookPath(fmt.Sprintf("./plugins/%s", fileName))
 	if err != nil {
 		server.Logger.Error("Could not load plugin %s", fileName)
+		return
 	}
 	cmd := exec.Command(path)
 	stdout, _ := cmd.StdoutPipe()
-	cmd.Start()
+	stdin, _ := cmd.StdinPipe()
+	if err := cmd.Start(); err != nil {
+		server.Logger.Error("Could not load plugin %s", fileName)
+		return
+	}
+	// messages are written by their own goroutine so a slow plugin can't stall the connections of players
+	outgoing := make(chan string, PluginQueueSize)
+	go func() {
+		for message := range outgoing {
+			if _, err := io.WriteString(stdin, message); err != nil {
+				return
+			}
+		}
+	}()
+	send := func(code int, format string, args ...interface{}) bool {
+		select {
+		case outgoing <- fmt.Sprintf("GoCraft|Message %d %s\n", code, fmt.Sprintf(format, args...)):
+			return true
+		default:
+			server.Logger.Warn("Plugin %s is not reading its messages, dropping one", fileName)
+			return false
+		}
+	}
+	var (
+		queryLock    sync.Mutex
+		queryID      int
+		queryResults = make(map[int]chan pluginLoginResult)
+	)
+	server.Plugins = append(server.Plugins, fileName)
 	scanner := bufio.NewScanner(stdout)
 	go func() {
 		for scanner.Scan() {
@@ -561,6 +709,90 @@ func (server Server) LoadPlugin(fileName string) {
 					}
 					server.Logger.Print("%v", message)
 				}
+			case PLUGINCODE_REGISTER_CHANNEL:
+				{
+					// messages on the channel are passed to the plugin as "<uuid> <channel> <base64 data>"
+					server.Channels.Register(command, func(player *Player, data []byte) {
+						send(PLUGINCODE_PLUGIN_MESSAGE, "%s %s %s", player.UUID.String, command, base64.StdEncoding.EncodeToString(data))
+					})
+				}
+			case PLUGINCODE_REGISTER_LOGIN_QUERY:
+				{
+					// "<channel> <base64 data>", the answers are passed to the plugin as "<id> <name> <channel> <understood> <base64 data>"
+					// and it replies with "<id> <true|false> [reason]"
+					args := strings.Fields(command)
+					if len(args) != 2 {
+						continue
+					}
+					data, err := base64.StdEncoding.DecodeString(args[1])
+					if err != nil {
+						continue
+					}
+					channel := args[0]
+					server.Channels.RegisterLoginQuery(channel, LoginQuery{Data: data, Handle: func(name string, understood bool, answer []byte) (bool, string) {
+						result := make(chan pluginLoginResult, 1)
+						queryLock.Lock()
+						queryID++
+						id := queryID
+						queryResults[id] = result
+						queryLock.Unlock()
+						defer func() {
+							queryLock.Lock()
+							delete(queryResults, id)
+							queryLock.Unlock()
+						}()
+						if !send(PLUGINCODE_LOGIN_QUERY, "%d %s %s %t %s", id, name, channel, understood, base64.StdEncoding.EncodeToString(answer)) {
+							return false, fmt.Sprintf("plugin %s is not responding", fileName)
+						}
+						select {
+						case r := <-result:
+							return r.allowed, r.reason
+						case <-time.After(PluginLoginQueryTimeout):
+							return false, fmt.Sprintf("plugin %s did not answer the login query", fileName)
+						}
+					}})
+				}
+			case PLUGINCODE_LOGIN_RESULT:
+				{
+					args := strings.SplitN(command, " ", 3)
+					if len(args) < 2 {
+						continue
+					}
+					id, err := strconv.Atoi(args[0])
+					if err != nil {
+						continue
+					}
+					r := pluginLoginResult{allowed: args[1] == "true"}
+					if len(args) == 3 {
+						r.reason = args[2]
+					}
+					queryLock.Lock()
+					result := queryResults[id]
+					queryLock.Unlock()
+					if result != nil {
+						select {
+						case result <- r:
+						default:
+						}
+					}
+				}
+			case PLUGINCODE_PLUGIN_MESSAGE:
+				{
+					args := strings.Fields(command)
+					if len(args) != 3 {
+						continue
+					}
+					data, err := base64.StdEncoding.DecodeString(args[2])
+					if err != nil {
+						continue
+					}
+					server.Players.Lock()
+					player := server.Players.Players[args[0]]
+					server.Players.Unlock()
+					if player != nil {
+						player.SendPluginMessage(args[1], data)
+					}
+				}
 			}
 		}
 	}()
@@ -570,12 +802,9 @@ func (server Server) BroadcastMessageAdmin(playerId string, message chat.Message
 	server.Logger.Print(message.String())
 	server.Players.Lock()
 	defer server.Players.Unlock()
-	ops := make(map[string]PlayerBase)
+	ops := make(map[string]OPEntry)
 	for i := 0; i < len(server.Players.OPs); i++ {
-		ops[server.Players.OPs[i].UUID] = PlayerBase{
-			Name: server.Players.OPs[i].Name,
-			UUID: server.Players.OPs[i].UUID,
-		}
+		ops[server.Players.OPs[i].UUID] = server.Players.OPs[i]
 	}
 	for _, player := range server.Players.Players {
 		if ops[player.UUID.String].UUID == player.UUID.String && player.UUID.String != playerId {
@@ -617,32 +846,18 @@ func LoopArray[V interface{}](data []V, do func(index int, value V, variables []
 	return variables
 }
 
-func (server Server) PlayerMessage(sender *Player, to string, data pk.Packet) {
-	var (
-		message       pk.String
-		timestampLong pk.Long
-		salt          pk.Long
-		signature     pk.Option[sign.Signature, *sign.Signature]
-		lastSeen      sign.HistoryUpdate
-	)
-	data.Scan(
-		&message,
-		&timestampLong,
-		&salt,
-		&signature,
-		&lastSeen,
-	)
+// formats a chat message with the chat format of the config, the message may only use the tags the sender is allowed to
+func (server Server) FormatChat(sender *Player, message *ChatMessage) chat.Message {
 	group, prefix, suffix := server.GetGroup(sender.UUID.String)
+	return FormatPlaceholders(TranslateColorCodes(server.Config.Chat.Format), Placeholders{PlayerName: sender.Name, PlayerPrefix: prefix, PlayerSuffix: suffix, Message: message.Content, PlayerGroup: group, Channel: chatChannelDisplayName(message.Channel)}, server.AllowedTags(sender.UUID.String))
+}
 
-	content := ParsePlaceholders(server.Config.Chat.Format, Placeholders{PlayerName: sender.Name, PlayerPrefix: prefix, PlayerSuffix: suffix, Message: fmt.Sprint(message), PlayerGroup: group})
-	if server.Config.Chat.Colors && server.HasPermissions(sender.UUID.String, []string{"server.chat.colors"}) {
-		content = strings.ReplaceAll(content, "&", "§")
-	}
+func (server Server) PlayerMessage(sender *Player, to string, message *ChatMessage) {
+	formatted := server.FormatChat(sender, message)
 	player, ok := server.Players.Players[to]
 	if !ok {
 		return
 	}
-	timestamp := time.UnixMilli(int64(timestampLong))
 	c := getNetworkRegistry().ChatType
 	chatTypeID, _ := c.Find("minecraft:chat")
 	chatType := chat.Type{
@@ -650,28 +865,54 @@ func (server Server) PlayerMessage(sender *Player, to string, data pk.Packet) {
 		SenderName: chat.Text(sender.Name),
 		TargetName: nil,
 	}
+	signature := pk.Option[sign.Signature, *sign.Signature]{}
+	lastSeen := []sign.PackedSignature{}
+	if message.Signature != nil {
+		signature = pk.Option[sign.Signature, *sign.Signature]{Has: true, Val: *message.Signature}
+		lastSeen = player.Chat.PackLastSeen(message.Body.LastSeen)
+	}
+	// the signed body has to stay what the sender typed, the formatted message is sent as unsigned content
+	unsigned := pk.Option[chat.Message, *chat.Message]{}
+	if message.ID != 0 && server.HasPermissions(to, []string{"server.command.delete"}) {
+		formatted.HoverEvent = ShowText(chat.Text("Click to delete this message"))
+		formatted.ClickEvent = chat.SuggestCommand(fmt.Sprintf("/delete %d", message.ID))
+		unsigned = pk.Option[chat.Message, *chat.Message]{Has: true, Val: formatted}
+	} else if formatted.Extra != nil || formatted.Text != message.Body.PlainMsg {
+		unsigned = pk.Option[chat.Message, *chat.Message]{Has: true, Val: formatted}
+	}
 	player.Connection.WritePacket(pk.Marshal(
 		packetid.ClientboundPlayerChat,
 		sender.UUID.Binary,
-		pk.VarInt(0),
+		pk.VarInt(message.Index),
 		signature,
 		&sign.PackedMessageBody{
-			PlainMsg:  content,
-			Timestamp: timestamp,
-			Salt:      int64(salt),
-			LastSeen:  []sign.PackedSignature{},
+			PlainMsg:  message.Body.PlainMsg,
+			Timestamp: message.Body.Timestamp,
+			Salt:      message.Body.Salt,
+			LastSeen:  lastSeen,
 		},
-		pk.Boolean(false),
+		unsigned,
 		&sign.FilterMask{Type: 0},
 		&chatType,
 	))
+	if message.Signature != nil && !player.Chat.AddPending(message) {
+		player.KickForChat(server.Config.Messages.TooManyPendingChats)
+	}
 }
 
-func (server Server) BroadcastPlayerMessage(sender *Player, data pk.Packet) {
+func (server Server) BroadcastPlayerMessage(sender *Player, message *ChatMessage) {
 	server.Players.Lock()
 	defer server.Players.Unlock()
-	for uuid := range server.Players.Players {
-		server.PlayerMessage(sender, uuid, data)
+	if message.Signature != nil {
+		message.ID = chatHistory.Add(sender, message.Signature)
+	}
+	if message.Channel != "" {
+		server.Logger.Print("[%s] %s", message.Channel, server.FormatChat(sender, message).String())
+	} else {
+		server.Logger.Print(server.FormatChat(sender, message).String())
+	}
+	for _, uuid := range server.ChatChannelRecipients(message.Channel, sender) {
+		server.PlayerMessage(sender, uuid, message)
 	}
 }
 
@@ -694,10 +935,88 @@ func (server Server) Message(id string, message chat.Message) {
 	player.Connection.WritePacket(pk.Marshal(packetid.ClientboundSystemChat, message, pk.Boolean(false)))
 }
 
+// replyTargets maps a player uuid or "console" to whoever they last messaged or were messaged by
+var replyTargets sync.Map
+
+func DecorateChatType(chatType string, content chat.Message, sender chat.Message, target *chat.Message) chat.Message {
+	reg := getNetworkRegistry().ChatType
+	id, t := reg.Find(chatType)
+	if t == nil {
+		return content
+	}
+	c := chat.Type{ID: id, SenderName: sender, TargetName: target}
+	return c.Decorate(content, &t.Chat)
+}
+
+// sends an unsigned message decorated by one of the chat types from the network registry
+func (server Server) ChatTypeMessage(id string, chatType string, content chat.Message, sender chat.Message, target *chat.Message) {
+	if id == "console" {
+		server.Logger.Print(DecorateChatType(chatType, content, sender, target).String())
+		return
+	}
+	player := server.Players.Players[id]
+	if player == nil {
+		return
+	}
+	reg := getNetworkRegistry().ChatType
+	chatTypeID, _ := reg.Find(chatType)
+	player.Connection.WritePacket(pk.Marshal(
+		packetid.ClientboundDisguisedChat,
+		content,
+		&chat.Type{ID: chatTypeID, SenderName: sender, TargetName: target},
+	))
+}
+
+func (server Server) BroadcastChatTypeMessage(chatType string, content chat.Message, sender chat.Message) {
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	server.ChatTypeMessage("console", chatType, content, sender, nil)
+	for id := range server.Players.Players {
+		server.ChatTypeMessage(id, chatType, content, sender, nil)
+	}
+}
+
+func (server Server) GetName(id string) string {
+	if id == "console" {
+		return "Server"
+	}
+	if id == "rcon" {
+		return "Rcon"
+	}
+	if player := server.Players.Players[id]; player != nil {
+		return player.Name
+	}
+	return id
+}
+
+func (server Server) PrivateMessage(from string, to string, message string) {
+	sender, target := chat.Text(server.GetName(from)), chat.Text(server.GetName(to))
+	content := chat.Text(message)
+	server.ChatTypeMessage(to, "minecraft:msg_command_incoming", content, sender, nil)
+	server.ChatTypeMessage(from, "minecraft:msg_command_outgoing", content, sender, &target)
+	replyTargets.Store(from, to)
+	replyTargets.Store(to, from)
+}
+
+func (server Server) Kick(id string, reason chat.Message) {
+	player := server.Players.Players[id]
+	if player == nil {
+		return
+	}
+	// the socket is closed after KickTimeout even if the client stopped reading and the write blocks
+	timer := time.AfterFunc(KickTimeout, func() { player.Connection.Close() })
+	player.Connection.WritePacket(pk.Marshal(packetid.ClientboundDisconnect, reason))
+	timer.Stop()
+	player.Connection.Close()
+}
+
 func (playerlist Playerlist) AddPlayer(player *Player) {
 	addPlayerAction := NewPlayerInfoAction(
 		PlayerInfoAddPlayer,
+		PlayerInfoInitializeChat,
+		PlayerInfoUpdateGameMode,
 		PlayerInfoUpdateListed,
+		PlayerInfoUpdateLatency,
 	)
 	var buf bytes.Buffer
 	_, _ = addPlayerAction.WriteTo(&buf)
@@ -706,19 +1025,44 @@ func (playerlist Playerlist) AddPlayer(player *Player) {
 		_, _ = pk.UUID(player.UUID.Binary).WriteTo(&buf)
 		_, _ = pk.String(player.Name).WriteTo(&buf)
 		_, _ = pk.Array(player.Properties).WriteTo(&buf)
+		session := player.Chat.Current()
+		_, _ = pk.Boolean(session != nil).WriteTo(&buf)
+		if session != nil {
+			_, _ = session.WriteTo(&buf)
+		}
+		_, _ = pk.VarInt(player.Data.PlayerGameType).WriteTo(&buf)
 		_, _ = pk.Boolean(true).WriteTo(&buf)
+		_, _ = pk.VarInt(player.Latency).WriteTo(&buf)
 	}
 	server.BroadcastPacket(pk.Packet{ID: int32(packetid.ClientboundPlayerInfoUpdate), Data: buf.Bytes()})
 }
 
+func (playerlist Playerlist) UpdateGameMode(player *Player) {
+	server.BroadcastPacket(pk.Marshal(packetid.ClientboundPlayerInfoUpdate,
+		NewPlayerInfoAction(PlayerInfoUpdateGameMode),
+		pk.VarInt(1),
+		player.UUID.Binary,
+		pk.VarInt(player.Data.PlayerGameType),
+	))
+}
+
+func (playerlist Playerlist) UpdateLatency(player *Player) {
+	server.BroadcastPacket(pk.Marshal(packetid.ClientboundPlayerInfoUpdate,
+		NewPlayerInfoAction(PlayerInfoUpdateLatency),
+		pk.VarInt(1),
+		player.UUID.Binary,
+		pk.VarInt(player.Latency),
+	))
+}
+
 func (playerlist Playerlist) RemovePlayer(player *Player) {
 	server.BroadcastPacket(pk.Marshal(packetid.ClientboundPlayerInfoRemove, pk.Array([]pk.UUID{player.UUID.Binary})))
 }
 
-func (playerlist Playerlist) GetTexts(player *Player) (string, string) {
+func (playerlist Playerlist) GetTexts(player *Player) (chat.Message, chat.Message) {
 	group, prefix, suffix := server.GetGroup(player.UUID.String)
-	header := ParsePlaceholders(strings.Join(server.Config.Tablist.Header, "\n"), Placeholders{PlayerName: player.Name, PlayerPrefix: prefix, PlayerGroup: group})
-	footer := ParsePlaceholders(strings.Join(server.Config.Tablist.Footer, "\n"), Placeholders{PlayerName: player.Name, PlayerSuffix: suffix, PlayerGroup: group})
+	header := FormatPlaceholders(strings.Join(server.Config.Tablist.Header, "\n"), Placeholders{PlayerName: player.Name, PlayerPrefix: prefix, PlayerGroup: group}, nil)
+	footer := FormatPlaceholders(strings.Join(server.Config.Tablist.Footer, "\n"), Placeholders{PlayerName: player.Name, PlayerSuffix: suffix, PlayerGroup: group}, nil)
 	return header, footer
 }
 
@@ -728,6 +1072,15 @@ type Placeholders struct {
 	PlayerGroup  string
 	PlayerPrefix string
 	PlayerSuffix string
+	Reason       string
+	Expires      string
+	Online       string
+	MaxPlayers   string
+	TPS          string
+	Time         string
+	Version      string
+	Protocol     string
+	Channel      string
 }
 
 func ParsePlaceholders(str string, placeholders Placeholders) string {
@@ -736,6 +1089,15 @@ func ParsePlaceholders(str string, placeholders Placeholders) string {
 	str = strings.ReplaceAll(str, "%player_prefix%", placeholders.PlayerPrefix)
 	str = strings.ReplaceAll(str, "%player_suffix%", placeholders.PlayerSuffix)
 	str = strings.ReplaceAll(str, "%player_group%", placeholders.PlayerGroup)
+	str = strings.ReplaceAll(str, "%reason%", placeholders.Reason)
+	str = strings.ReplaceAll(str, "%expires%", placeholders.Expires)
+	str = strings.ReplaceAll(str, "%online%", placeholders.Online)
+	str = strings.ReplaceAll(str, "%max_players%", placeholders.MaxPlayers)
+	str = strings.ReplaceAll(str, "%tps%", placeholders.TPS)
+	str = strings.ReplaceAll(str, "%time%", placeholders.Time)
+	str = strings.ReplaceAll(str, "%version%", placeholders.Version)
+	str = strings.ReplaceAll(str, "%protocol%", placeholders.Protocol)
+	str = strings.ReplaceAll(str, "%channel%", placeholders.Channel)
 	str = strings.TrimSpace(str)
 	return str
 }
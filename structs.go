@@ -4,6 +4,7 @@ import (
 	"dynamite/logger"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/Tnze/go-mc/level"
 	"github.com/Tnze/go-mc/net"
@@ -92,8 +93,8 @@ type Player struct {
 	Properties   []user.Property
 	Client       ClientData
 	IP           string
-	Position     [3]int32
-	OldPosition  [3]int32
+	Position     [3]float64
+	OldPosition  [3]float64
 	Rotation     [2]float32
 	ChunkPos     [3]int32
 	LoadedChunks map[[2]int32]struct{}
@@ -102,6 +103,22 @@ type Player struct {
 	Data         PlayerData
 	LastTick     uint
 	EntityID     int
+	// the id of the last teleport that the client hasn't confirmed yet, or 0
+	PendingTeleport int
+	// the id of the keep alive that the client hasn't answered yet, or 0, ids are the unix time in milliseconds they were sent at
+	KeepAliveID   int64
+	LastKeepAlive time.Time
+	// round trip time in milliseconds, averaged over keep alives like vanilla
+	Latency int
+	// the plugin channels the client registered
+	Channels map[string]struct{}
+	Chat     *ChatSession
+	// the last chat message of the player in lower case and how often it was sent in a row
+	LastChatMessage string
+	ChatRepeats     int
+	// the chat channels the player joined and the one it talks in
+	ChatChannels   map[string]bool
+	FocusedChannel string
 }
 
 const (
@@ -110,6 +127,13 @@ const (
 	CHAT_HIDDEN
 )
 
+const (
+	GAMEMODE_SURVIVAL = iota
+	GAMEMODE_CREATIVE
+	GAMEMODE_ADVENTURE
+	GAMEMODE_SPECTATOR
+)
+
 const (
 	LEFT_HAND = iota
 	RIGHT_HAND
@@ -121,9 +145,21 @@ const (
 	FAVICON_INVALID_DIMENSIONS
 )
 
+const (
+	THROTTLE_OK = iota
+	THROTTLE_TOO_FAST
+	THROTTLE_TOO_MANY_CONNECTIONS
+	THROTTLE_SERVER_BUSY
+)
+
 const (
 	PLUGINCODE_INFO = iota
 	PLUGINCODE_LOG
+	PLUGINCODE_REGISTER_CHANNEL
+	PLUGINCODE_PLUGIN_MESSAGE
+	PLUGINCODE_REGISTER_LOGIN_QUERY
+	PLUGINCODE_LOGIN_QUERY
+	PLUGINCODE_LOGIN_RESULT
 )
 
 type Events struct {
@@ -148,9 +184,11 @@ type PlayersC struct {
 	PlayerNames   map[string]string
 	PlayerIDs     []string
 	Whitelist     []PlayerBase
-	OPs           []PlayerBase
-	BannedPlayers []PlayerBase
-	BannedIPs     []string
+	OPs           []OPEntry
+	BannedPlayers []BanEntry
+	BannedIPs     []BanEntry
+	IPBanTree     *IPTree
+	MutedPlayers  []BanEntry
 }
 
 type Server struct {
@@ -159,6 +197,7 @@ type Server struct {
 	Events          Events
 	Config          *Config
 	Logger          *logger.Logger
+	Console         *Console
 	Playerlist      Playerlist
 	StartTime       int64
 	Favicon         []byte
@@ -168,6 +207,8 @@ type Server struct {
 	TeleportCounter int
 	Mojang          MojangAPI
 	Worlds          map[string]World
+	Plugins         []string
+	Channels        *Channels
 }
 
 type Node struct {
@@ -1,21 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
-	"encoding/base64"
 
-	r "math/rand"
 	"time"
 
 	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 
 	_ "image/png"
 	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"unicode/utf16"
 
 	"github.com/Tnze/go-mc/chat"
 	"github.com/Tnze/go-mc/data/packetid"
@@ -77,6 +79,15 @@ const (
 	PROTOCOL_1_7    = 3
 )
 
+const (
+	// handshake and status requests
+	HandshakeTimeout = 10 * time.Second
+	// everything from the handshake to login success, including authentication
+	LoginTimeout      = 30 * time.Second
+	KeepAliveInterval = 15 * time.Second
+	KeepAliveTimeout  = 30 * time.Second
+)
+
 func getNetworkRegistry() (reg registry.NetworkCodec) {
 	data, _ := registries.ReadFile("registry.nbt")
 	nbt.Unmarshal(data, &reg)
@@ -116,9 +127,39 @@ func TCPListen() {
 
 func HandleTCPRequest(conn net.Conn) {
 	defer conn.Close()
+	conn.Socket.SetDeadline(time.Now().Add(HandshakeTimeout))
+	if server.Config.ProxyProtocol.Enable && IsTrustedProxy(RemoteAddress(conn.Socket.RemoteAddr().String()), server.Config.ProxyProtocol.TrustedProxies) {
+		addr, err := ReadProxyHeader(conn.Socket)
+		if err != nil {
+			server.Logger.Debug("[TCP] [%s] Failed to read PROXY protocol header: %s", conn.Socket.RemoteAddr(), err)
+			return
+		}
+		if addr != nil {
+			socket := &proxiedConn{Conn: conn.Socket, remote: addr}
+			conn.Socket, conn.Reader, conn.Writer = socket, socket, socket
+		}
+	}
+	socket := &deadlineConn{Conn: conn.Socket}
+	conn.Socket, conn.Reader, conn.Writer = socket, socket, socket
+	ip := conn.Socket.RemoteAddr().String()
+	address := RemoteAddress(ip)
+	open := connections.Open(address)
+	defer connections.Close(address)
+	// legacy clients start with 0xFE instead of a VarInt framed handshake, only one byte is read
+	// so that the socket can still be used directly once encryption is enabled
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(conn.Socket, first); err != nil {
+		return
+	}
+	if first[0] == 0xFE {
+		if pingLimiter.Allow(address, server.Config.ServerList.RateLimit) {
+			handleLegacyPing(conn, ip)
+		}
+		return
+	}
+	conn.Reader = io.MultiReader(bytes.NewReader(first), conn.Socket)
 	var packet pk.Packet
 	conn.ReadPacket(&packet)
-	ip := conn.Socket.RemoteAddr().String()
 	server.Logger.Debug("[TCP] ([%s] -> Server) Sent handshake", ip)
 	var (
 		Protocol, Intention pk.VarInt
@@ -132,9 +173,17 @@ func HandleTCPRequest(conn net.Conn) {
 
 	switch Intention {
 	case packetid.StatusPingRequest:
+		if !pingLimiter.Allow(address, server.Config.ServerList.RateLimit) {
+			server.Logger.Debug("[TCP] [%s] is pinging too quickly", ip)
+			return
+		}
+		if max := server.Config.Throttle.MaxConnections; max > 0 && open > max && !ForwardingEnabled() {
+			return
+		}
 		handleTCPPing(conn, Protocol, ip) // Ping
 	case 2:
 		{ // login
+			conn.Socket.SetDeadline(time.Now().Add(LoginTimeout))
 			if PROTOCOL_1_19_4 > int(Protocol) {
 				conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(server.Config.Messages.ProtocolOld)))
 				conn.Close()
@@ -145,6 +194,49 @@ func HandleTCPRequest(conn net.Conn) {
 				conn.Close()
 				return
 			}
+			var forwarded *auth.Resp
+			if server.Config.Forwarding.Mode == FORWARDING_BUNGEECORD {
+				// anyone could claim any player otherwise
+				if !IsTrustedProxy(address, server.Config.Forwarding.TrustedProxies) {
+					server.Logger.Warn("[%s] Connection refused. reason: BungeeCord forwarding from an untrusted address", ip)
+					conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(server.Config.Messages.ProxyRequired)))
+					return
+				}
+				var forwardedIP string
+				forwardedIP, forwarded, err = ParseBungeeCordForwarding(string(ServerAddress))
+				if err != nil {
+					server.Logger.Info("[%s] Connection refused. reason: %s", ip, err)
+					conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(server.Config.Messages.ProxyRequired)))
+					return
+				}
+				ip, address = forwardedIP, forwardedIP
+			}
+			throttle, finishLogin := connections.BeginLogin(address)
+			if throttle != THROTTLE_OK {
+				var reason string
+				var reasonNice string
+				switch throttle {
+				case THROTTLE_TOO_FAST:
+					{
+						reason = "connecting too quickly"
+						reasonNice = server.Config.Messages.Throttled
+					}
+				case THROTTLE_TOO_MANY_CONNECTIONS:
+					{
+						reason = "too many connections"
+						reasonNice = server.Config.Messages.TooManyConnections
+					}
+				case THROTTLE_SERVER_BUSY:
+					{
+						reason = "too many pending logins"
+						reasonNice = server.Config.Messages.ServerBusy
+					}
+				}
+				server.Logger.Info("[%s] Connection throttled. reason: %s", ip, reason)
+				conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(reasonNice)))
+				return
+			}
+			defer finishLogin()
 			var p pk.Packet
 			conn.ReadPacket(&p)
 			var (
@@ -163,7 +255,26 @@ func HandleTCPRequest(conn net.Conn) {
 				return
 			}
 			var resp *auth.Resp
-			resp, err = auth.Encrypt(&conn, fmt.Sprint(name), serverKey)
+			switch {
+			case forwarded != nil:
+				{
+					resp = forwarded
+					resp.Name = string(name)
+				}
+			case server.Config.Forwarding.Mode == FORWARDING_VELOCITY:
+				{
+					var forwardedIP string
+					forwardedIP, resp, err = VelocityForwarding(&conn)
+					if err != nil {
+						server.Logger.Info("[%s] Connection refused. reason: %s", ip, err)
+						conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(server.Config.Messages.ProxyRequired)))
+						return
+					}
+					ip, address = forwardedIP, forwardedIP
+				}
+			default:
+				resp, err = auth.Encrypt(&conn, fmt.Sprint(name), serverKey)
+			}
 			if err != nil {
 				if server.Config.Online {
 					conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(server.Config.Messages.OnlineMode)))
@@ -179,7 +290,7 @@ func HandleTCPRequest(conn net.Conn) {
 				properties = resp.Properties
 			}
 			server.Logger.Info("[%s] Player %s (%s) is attempting to join", ip, name, idString)
-			valid := ValidatePlayer(fmt.Sprint(name), idString, strings.Split(ip, ":")[0])
+			valid, ban := ValidatePlayer(fmt.Sprint(name), idString, address)
 			if valid != 0 {
 				var reason string
 				var reasonNice string
@@ -192,7 +303,7 @@ func HandleTCPRequest(conn net.Conn) {
 				case 2:
 					{
 						reason = "player is banned"
-						reasonNice = server.Config.Messages.Banned
+						reasonNice = ban.Message()
 					}
 				case 3:
 					{
@@ -208,6 +319,16 @@ func HandleTCPRequest(conn net.Conn) {
 				r := chat.Text(reasonNice)
 				server.Logger.Info("[%s] Player %s (%s) attempt failed. reason: %s", ip, name, idString, reason)
 				conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, r))
+				return
+			}
+			if threshold := server.Config.CompressionThreshold; threshold >= 0 {
+				conn.WritePacket(pk.Marshal(packetid.LoginCompression, pk.VarInt(threshold)))
+				conn.SetThreshold(threshold)
+			}
+			if ok, reason := server.Channels.RunLoginQueries(&conn, string(name)); !ok {
+				server.Logger.Info("[%s] Player %s (%s) attempt failed. reason: %s", ip, name, idString, reason)
+				conn.WritePacket(pk.Marshal(packetid.LoginDisconnect, chat.Text(server.Config.Messages.LoginQueryFailed)))
+				return
 			}
 			conn.WritePacket(pk.Marshal(
 				packetid.LoginSuccess,
@@ -215,15 +336,10 @@ func HandleTCPRequest(conn net.Conn) {
 				pk.String(name),
 				pk.Array(properties),
 			))
-			gamemode := 0
-			if server.Config.Gamemode == "creative" {
-				gamemode = 1
-			}
-			if server.Config.Gamemode == "adventure" {
-				gamemode = 2
-			}
-			if server.Config.Gamemode == "spectator" {
-				gamemode = 3
+			finishLogin()
+			gamemode, ok := ParseGamemode(server.Config.Gamemode)
+			if !ok {
+				gamemode = GAMEMODE_SURVIVAL
 			}
 			hashedSeed := [8]byte{}
 			var dimensions []pk.Identifier
@@ -263,7 +379,7 @@ func HandleTCPRequest(conn net.Conn) {
 					HurtTime:            0,
 					SleepTimer:          0,
 					SeenCredits:         0,
-					PlayerGameType:      1,
+					PlayerGameType:      int32(gamemode),
 					FoodLevel:           20,
 					FoodExhaustionLevel: 0,
 					FoodSaturationLevel: 5,
@@ -283,12 +399,15 @@ func HandleTCPRequest(conn net.Conn) {
 				}
 				server.WritePlayerData(idString, *data)
 			}
+			if server.Config.ForceGamemode {
+				data.PlayerGameType = int32(gamemode)
+			}
 			entityId := server.NewEntityID()
 			conn.WritePacket(pk.Marshal(
 				packetid.ClientboundLogin,
 				pk.Int(entityId),
 				pk.Boolean(server.Config.Hardcore),
-				pk.UnsignedByte(gamemode),
+				pk.UnsignedByte(data.PlayerGameType),
 				pk.Byte(-1),
 				pk.Array(dimensions),
 				pk.NBT(getNetworkRegistry()),
@@ -304,6 +423,7 @@ func HandleTCPRequest(conn net.Conn) {
 				pk.Boolean(false),
 				pk.Boolean(false),
 			))
+			teleportId := server.NewTeleportID()
 			conn.WritePacket(pk.Marshal(packetid.ClientboundPlayerPosition,
 				pk.Double(data.Pos[0]),     //x
 				pk.Double(data.Pos[1]),     //y
@@ -311,7 +431,7 @@ func HandleTCPRequest(conn net.Conn) {
 				pk.Float(data.Rotation[0]), //yaw
 				pk.Float(data.Rotation[1]), //pitch
 				pk.Byte(0),
-				pk.VarInt(server.NewTeleportID()),
+				pk.VarInt(teleportId),
 			))
 			conn.WritePacket(pk.Marshal(packetid.ClientboundSetDefaultSpawnPosition,
 				pk.Position{X: int(server.Level.Data.SpawnX), Y: int(server.Level.Data.SpawnY), Z: int(server.Level.Data.SpawnZ)},
@@ -323,7 +443,6 @@ func HandleTCPRequest(conn net.Conn) {
 					data,
 				))
 			}
-			var lastKeepAliveId int
 			player := &Player{
 				Name: fmt.Sprint(name),
 				UUID: UUID{
@@ -334,11 +453,22 @@ func HandleTCPRequest(conn net.Conn) {
 				Properties:   properties,
 				IP:           ip,
 				LoadedChunks: make(map[[2]int32]struct{}),
+				Channels:     make(map[string]struct{}),
+				Chat:         NewChatSession(),
 				Data:         *data,
 				EntityID:     entityId,
-				OldPosition:  [3]int32{-1, -1, -1},
+				Position:     [3]float64{data.Pos[0], data.Pos[1], data.Pos[2]},
+				OldPosition:  [3]float64{-1, -1, -1},
+				Rotation:     [2]float32{data.Rotation[0], data.Rotation[1]},
+				ChunkPos:     [3]int32{math.MaxInt32, math.MaxInt32, math.MaxInt32},
+
+				PendingTeleport: teleportId,
 			}
+			player.InitChatChannels()
+			player.SendAbilities()
 			joined := false
+			done := make(chan struct{})
+			defer close(done)
 			for {
 				var packet pk.Packet
 				err := conn.ReadPacket(&packet)
@@ -372,37 +502,63 @@ func HandleTCPRequest(conn net.Conn) {
 						server.Players.PlayerNames[fmt.Sprint(name)] = idString
 						server.Players.PlayerIDs = append(server.Players.PlayerIDs, idString)
 						server.Players.Unlock()
+						InvalidateStatusCache()
 						joined = true
 
 						server.Logger.Info("[%s] Player %s (%s) joined the server", ip, name, idString)
 						server.Events.Emit("PlayerJoin", player, conn)
-						ticker := time.NewTicker(15 * time.Second)
-						defer ticker.Stop()
-						go func() {
-							for range ticker.C {
-								lastKeepAliveId = r.Intn(1000)
-								conn.WritePacket(pk.Marshal(packetid.ClientboundKeepAlive, pk.Long(lastKeepAliveId)))
-								server.Logger.Debug("[TCP] (Server -> [%s]) Sent KeepAlive packet", ip)
-							}
-						}()
+						// keep alives take over from the login read deadline, writes keep their own deadline
+						conn.Socket.SetReadDeadline(time.Time{})
+						go player.KeepAliveLoop(done)
 					}
 				case int32(packetid.ServerboundKeepAlive):
 					{
 						var id pk.Long
 						packet.Scan(&id)
-						if id != pk.Long(lastKeepAliveId) {
+						server.Logger.Debug("[TCP] ([%s] -> Server) Sent KeepAlive packet", ip)
+						if !player.AnswerKeepAlive(int64(id)) {
+							server.Logger.Info("[%s] Player %s (%s) sent an invalid keep alive", ip, name, idString)
 							conn.Close()
-							server.Events.Emit("PlayerLeave", player)
-							break
 						}
-						server.Logger.Debug("[TCP] ([%s] -> Server) Sent KeepAlive packet", ip)
 					}
 				case int32(packetid.ServerboundChatCommand):
 					{
-						var command pk.String
-
-						packet.Scan(&command)
-						server.Events.Emit("PlayerCommand", player, command)
+						command, reason := player.ReadChatCommand(packet)
+						if reason != "" {
+							player.KickForChat(reason)
+							continue
+						}
+						server.Events.Emit("PlayerCommand", player, pk.String(command))
+					}
+				case int32(packetid.ServerboundCommandSuggestion):
+					{
+						var (
+							id   pk.VarInt
+							text pk.String
+						)
+						packet.Scan(&id, &text)
+						line := strings.TrimPrefix(string(text), "/")
+						start, suggestions := server.Suggest(idString, line)
+						matches := make([]pk.Tuple, len(suggestions))
+						for i, suggestion := range suggestions {
+							matches[i] = pk.Tuple{pk.String(suggestion), pk.Boolean(false)}
+						}
+						// the client counts the range in UTF-16 code units, start is a byte offset into line
+						prefix := string(text)[:start+len(text)-len(line)]
+						conn.WritePacket(pk.Marshal(packetid.ClientboundCommandSuggestions,
+							id,
+							pk.VarInt(len(utf16.Encode([]rune(prefix)))),
+							pk.VarInt(len(utf16.Encode([]rune(line[start:])))),
+							pk.Array(matches),
+						))
+					}
+				case int32(packetid.ServerboundAcceptTeleportation):
+					{
+						var id pk.VarInt
+						packet.Scan(&id)
+						if int(id) == player.PendingTeleport {
+							player.PendingTeleport = 0
+						}
 					}
 				case int32(packetid.ServerboundMovePlayerPos):
 					{
@@ -412,11 +568,10 @@ func HandleTCPRequest(conn net.Conn) {
 							z pk.Double
 						)
 						packet.Scan(&x, &y, &z)
-						if player.Position == [3]int32{int32(x), int32(y), int32(z)} {
+						if player.PendingTeleport != 0 {
 							continue
 						}
-						player.OldPosition = player.Position
-						player.Position = [3]int32{int32(x), int32(y), int32(z)}
+						player.Move(float64(x), float64(y), float64(z))
 					}
 				case int32(packetid.ServerboundMovePlayerPosRot):
 					{
@@ -428,11 +583,11 @@ func HandleTCPRequest(conn net.Conn) {
 							pitch pk.Float
 						)
 						packet.Scan(&x, &y, &z, &yaw, &pitch)
-						if player.Position == [3]int32{int32(x), int32(y), int32(z)} {
+						if player.PendingTeleport != 0 {
 							continue
 						}
-						player.OldPosition = player.Position
-						player.Position = [3]int32{int32(x), int32(y), int32(z)}
+						player.Move(float64(x), float64(y), float64(z))
+						player.Rotation = [2]float32{float32(yaw), float32(pitch)}
 					}
 				case int32(packetid.ServerboundMovePlayerRot):
 					{
@@ -441,22 +596,40 @@ func HandleTCPRequest(conn net.Conn) {
 							pitch pk.Float
 						)
 						packet.Scan(&yaw, &pitch)
+						if player.PendingTeleport != 0 {
+							continue
+						}
 						player.Rotation = [2]float32{float32(yaw), float32(pitch)}
 					}
 				case int32(packetid.ServerboundChat):
 					{
-						server.Events.Emit("PlayerChatMessage", player, packet)
+						message, reason := player.ReadChatMessage(packet)
+						if message == nil {
+							player.KickForChat(reason)
+							continue
+						}
+						server.Events.Emit("PlayerChatMessage", player, message)
+					}
+				case int32(packetid.ServerboundChatAck):
+					{
+						if reason := player.AcknowledgeChat(packet); reason != "" {
+							player.KickForChat(reason)
+						}
 					}
-				case 0x0D:
+				case int32(packetid.ServerboundChatSessionUpdate):
+					{
+						if reason := player.UpdateChatSession(packet); reason != "" {
+							player.KickForChat(reason)
+						}
+					}
+				case int32(packetid.ServerboundCustomPayload):
 					{
 						var (
 							channel pk.Identifier
-							data    pk.String
+							data    pk.PluginMessageData
 						)
 						packet.Scan(&channel, &data)
-						if channel == "minecraft:brand" {
-							player.Client.Brand = data
-						}
+						server.Channels.Handle(player, string(channel), data)
 					}
 				}
 			}
@@ -471,46 +644,7 @@ func handleTCPPing(conn net.Conn, Protocol pk.VarInt, ip string) {
 		switch p.ID {
 		case packetid.StatusRequest:
 			server.Logger.Debug("[TCP] ([%s] -> Server) Sent StatusRequest packet", ip)
-			max := server.Config.MaxPlayers
-			if max == -1 {
-				max = len(server.Players.Players) + 1
-			}
-			players := server.Players.AsBase()
-			response := StatusResponse{
-				Version: Version{
-					Name:     "GoCraft 1.19.4",
-					Protocol: PROTOCOL_1_19_4,
-				},
-				Players: Players{
-					Max:    max,
-					Online: len(players),
-					Sample: players,
-				},
-				Description: Description{
-					Text: server.Config.MOTD,
-				},
-				EnforcesSecureChat: true,
-				PreviewsChat:       true,
-			}
-			if server.Config.Icon.Enable {
-				success, code, data := server.GetFavicon()
-				if !success {
-					switch code {
-					case FAVICON_NOTFOUND:
-						{
-							server.Logger.Warn("Server icon is enabled but wasn't found; ignoring")
-						}
-					case FAVICON_INVALID_FORMAT, FAVICON_INVALID_DIMENSIONS:
-						{
-							server.Logger.Debug("Server icon is not a 64x64 png file; ignoring")
-						}
-					}
-				} else {
-					icon := base64.StdEncoding.EncodeToString(data)
-					response.Favicon = fmt.Sprintf("data:image/png;base64,%s", icon)
-				}
-			}
-			conn.WritePacket(pk.Marshal(0x00, pk.String(CreateStatusResponse(response))))
+			conn.WritePacket(pk.Marshal(0x00, pk.String(server.GetStatusResponse(int(Protocol)))))
 			server.Logger.Debug("[TCP] (Server -> [%s]) Sent StatusResponse packet", ip)
 		case packetid.StatusPingRequest:
 			server.Logger.Debug("[TCP] ([%s] -> Server) Sent StatusPingRequest packet", ip)
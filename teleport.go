@@ -0,0 +1,362 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Tnze/go-mc/chat"
+	"github.com/Tnze/go-mc/data/packetid"
+	pk "github.com/Tnze/go-mc/net/packet"
+)
+
+const (
+	TELEPORT_RELATIVE_YAW   = 0x08
+	TELEPORT_RELATIVE_PITCH = 0x10
+)
+
+var ErrMixedCoordinates = errors.New("cannot mix world and local coordinates")
+
+func (world World) DimensionType() string {
+	switch world.Name {
+	case "minecraft:nether":
+		return "minecraft:the_nether"
+	case "minecraft:the_end":
+		return "minecraft:the_end"
+	default:
+		return "minecraft:overworld"
+	}
+}
+
+func (player *Player) Move(x, y, z float64) {
+	player.OldPosition = player.Position
+	player.Position = [3]float64{x, y, z}
+	player.Data.Pos = []float64{x, y, z}
+}
+
+// teleports the player, keeping their current rotation if rotation is nil
+func (player *Player) Teleport(world string, position [3]float64, rotation *[2]float32) {
+	if world != player.Data.Dimension {
+		player.ChangeWorld(world)
+	}
+	var flags byte
+	var yaw, pitch float32
+	if rotation != nil {
+		yaw, pitch = rotation[0], rotation[1]
+		player.Rotation = *rotation
+		player.Data.Rotation = []float32{yaw, pitch}
+	} else {
+		flags = TELEPORT_RELATIVE_YAW | TELEPORT_RELATIVE_PITCH
+	}
+	player.Move(position[0], position[1], position[2])
+	player.PendingTeleport = server.NewTeleportID()
+	player.Connection.WritePacket(pk.Marshal(packetid.ClientboundPlayerPosition,
+		pk.Double(position[0]), //x
+		pk.Double(position[1]), //y
+		pk.Double(position[2]), //z
+		pk.Float(yaw),          //yaw
+		pk.Float(pitch),        //pitch
+		pk.Byte(flags),
+		pk.VarInt(player.PendingTeleport),
+	))
+	server.BroadcastPacketExcept(pk.Marshal(packetid.ClientboundTeleportEntity,
+		pk.VarInt(player.EntityID),
+		pk.Double(position[0]),
+		pk.Double(position[1]),
+		pk.Double(position[2]),
+		pk.Angle(player.Rotation[0]),
+		pk.Angle(player.Rotation[1]),
+		pk.Boolean(false),
+	), player.UUID.String)
+}
+
+// moves the player to another world, the chunks of the new world are sent on the next tick
+func (player *Player) ChangeWorld(name string) {
+	world, ok := server.Worlds[name]
+	if !ok {
+		return
+	}
+	oldWorld := player.Data.Dimension
+	if old, ok := server.Worlds[oldWorld]; ok {
+		old.TickLock.Lock()
+		defer old.TickLock.Unlock()
+		for pos := range player.LoadedChunks {
+			if chunk := old.Chunks[pos]; chunk != nil {
+				chunk.RemoveViewer(player.UUID.String)
+			}
+		}
+	}
+	player.Lock()
+	player.LoadedChunks = make(map[[2]int32]struct{})
+	player.ChunkPos = [3]int32{math.MaxInt32, math.MaxInt32, math.MaxInt32}
+	player.Data.Dimension = name
+	player.Unlock()
+	player.Connection.WritePacket(pk.Marshal(packetid.ClientboundRespawn,
+		pk.Identifier(world.DimensionType()),
+		pk.Identifier(world.Name),
+		pk.Long(0),
+		pk.UnsignedByte(player.Data.PlayerGameType),
+		pk.Byte(-1),
+		pk.Boolean(false),
+		pk.Boolean(false),
+		pk.Byte(0),
+		pk.Boolean(false),
+	))
+	player.SendAbilities()
+	// the client forgets its entities on respawn, the players of the old world forget this one
+	// and the players of both worlds see each other
+	server.Players.Lock()
+	defer server.Players.Unlock()
+	for _, p := range server.Players.Players {
+		if p == player {
+			continue
+		}
+		switch p.Data.Dimension {
+		case oldWorld:
+			p.Connection.WritePacket(pk.Marshal(packetid.ClientboundRemoveEntities, pk.Array([]pk.VarInt{pk.VarInt(player.EntityID)})))
+		case name:
+			{
+				p.Connection.WritePacket(player.SpawnPacket())
+				player.Connection.WritePacket(p.SpawnPacket())
+			}
+		}
+	}
+}
+
+// the packet that spawns the player for other clients
+func (player *Player) SpawnPacket() pk.Packet {
+	return pk.Marshal(packetid.ClientboundAddPlayer,
+		pk.VarInt(player.EntityID),
+		player.UUID.Binary,
+		pk.Double(player.Position[0]),
+		pk.Double(player.Position[1]),
+		pk.Double(player.Position[2]),
+		pk.Angle(player.Rotation[0]),
+		pk.Angle(player.Rotation[1]),
+	)
+}
+
+type Coordinate struct {
+	Value    float64
+	Relative bool
+	Local    bool
+}
+
+// parses a coordinate like 10, ~5 or ^-2, center adds .5 to whole numbers like vanilla does for x and z
+func ParseCoordinate(str string, center bool) (Coordinate, error) {
+	var c Coordinate
+	if strings.HasPrefix(str, "~") {
+		c.Relative = true
+		str = str[1:]
+	} else if strings.HasPrefix(str, "^") {
+		c.Local = true
+		str = str[1:]
+	}
+	if str == "" {
+		if !c.Relative && !c.Local {
+			return c, fmt.Errorf("expected a coordinate")
+		}
+		return c, nil
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return c, fmt.Errorf("%s is not a valid coordinate", str)
+	}
+	if center && !c.Relative && !c.Local && !strings.Contains(str, ".") {
+		value += 0.5
+	}
+	c.Value = value
+	return c, nil
+}
+
+func IsCoordinate(str string) bool {
+	_, err := ParseCoordinate(str, false)
+	return err == nil
+}
+
+// resolves x y z arguments relative to the source position and rotation
+func ParseLocation(args []string, source [3]float64, rotation [2]float32) ([3]float64, error) {
+	var coords [3]Coordinate
+	for i := range coords {
+		c, err := ParseCoordinate(args[i], i != 1)
+		if err != nil {
+			return [3]float64{}, err
+		}
+		coords[i] = c
+	}
+	local := coords[0].Local
+	for _, c := range coords {
+		if c.Local != local {
+			return [3]float64{}, ErrMixedCoordinates
+		}
+	}
+	if local {
+		return LocalToWorld(source, rotation, coords[0].Value, coords[1].Value, coords[2].Value), nil
+	}
+	var pos [3]float64
+	for i, c := range coords {
+		pos[i] = c.Value
+		if c.Relative {
+			pos[i] += source[i]
+		}
+	}
+	return pos, nil
+}
+
+// converts ^left ^up ^forward coordinates to world coordinates, the same way vanilla does
+func LocalToWorld(source [3]float64, rotation [2]float32, left, up, forward float64) [3]float64 {
+	rad := math.Pi / 180
+	yaw, pitch := float64(rotation[0]), float64(rotation[1])
+	f, g := math.Cos((yaw+90)*rad), math.Sin((yaw+90)*rad)
+	h, i := math.Cos(-pitch*rad), math.Sin(-pitch*rad)
+	j, k := math.Cos((-pitch+90)*rad), math.Sin((-pitch+90)*rad)
+	fw := [3]float64{f * h, i, g * h}
+	u := [3]float64{f * j, k, g * j}
+	l := [3]float64{
+		-(fw[1]*u[2] - fw[2]*u[1]),
+		-(fw[2]*u[0] - fw[0]*u[2]),
+		-(fw[0]*u[1] - fw[1]*u[0]),
+	}
+	var pos [3]float64
+	for n := range pos {
+		pos[n] = source[n] + fw[n]*forward + u[n]*up + l[n]*left
+	}
+	return pos
+}
+
+// parses yaw and pitch arguments, ~ is relative to the source rotation
+func ParseRotation(args []string, rotation [2]float32) ([2]float32, error) {
+	var rot [2]float32
+	for i := range rot {
+		c, err := ParseCoordinate(args[i], false)
+		if err != nil || c.Local {
+			return rot, fmt.Errorf("%s is not a valid rotation", args[i])
+		}
+		rot[i] = float32(c.Value)
+		if c.Relative {
+			rot[i] += rotation[i]
+		}
+	}
+	return rot, nil
+}
+
+// returns the rotation of an entity's eyes at from looking at to
+func FacingRotation(from [3]float64, to [3]float64) [2]float32 {
+	dx, dy, dz := to[0]-from[0], to[1]-(from[1]+1.62), to[2]-from[2]
+	yaw := math.Atan2(dz, dx)*180/math.Pi - 90
+	pitch := -math.Atan2(dy, math.Sqrt(dx*dx+dz*dz)) * 180 / math.Pi
+	return [2]float32{float32(yaw), float32(pitch)}
+}
+
+/*
+	/tp <destination>
+	/tp <location>
+	/tp <targets> <destination>
+	/tp <targets> <location> [<yaw> <pitch>]
+	/tp <targets> <location> facing <x> <y> <z>
+	/tp <targets> <location> facing entity <entity> [eyes|feet]
+*/
+
+func (server *Server) TeleportCommand(executor *Player, args []string) chat.Message {
+	source := [3]float64{float64(server.Level.Data.SpawnX), float64(server.Level.Data.SpawnY), float64(server.Level.Data.SpawnZ)}
+	var sourceRotation [2]float32
+	// locations are in the dimension of the executor, the console and rcon are in the overworld
+	dimension := "minecraft:overworld"
+	if executor != nil {
+		source, sourceRotation, dimension = executor.Position, executor.Rotation, executor.Data.Dimension
+	}
+
+	var targets []*Player
+	if len(args) == 1 || (len(args) == 3 && IsCoordinate(args[0])) {
+		if executor == nil {
+			return chat.Text("§cOnly players can be teleported")
+		}
+		targets = []*Player{executor}
+	} else {
+		if len(args) == 0 {
+			return chat.Text("§cInvalid amount of arguments")
+		}
+		targets = ParseTargets(executor, args[0])
+		if len(targets) == 0 {
+			return chat.Text("§cNo player was found")
+		}
+		args = args[1:]
+	}
+
+	var targetNames string
+	if len(targets) == 1 {
+		targetNames = targets[0].Name
+	} else {
+		targetNames = fmt.Sprintf("%d players", len(targets))
+	}
+
+	if len(args) == 1 {
+		destinations := ParseTargets(executor, args[0])
+		if len(destinations) != 1 {
+			return chat.Text("§cUnknown destination player")
+		}
+		destination := destinations[0]
+		for _, target := range targets {
+			rotation := destination.Rotation
+			target.Teleport(destination.Data.Dimension, destination.Position, &rotation)
+		}
+		return chat.Text(fmt.Sprintf("Teleported %s to %s", targetNames, destination.Name))
+	}
+
+	if len(args) < 3 {
+		return chat.Text("§cInvalid amount of arguments")
+	}
+	position, err := ParseLocation(args[:3], source, sourceRotation)
+	if err != nil {
+		return chat.Text("§c" + err.Error())
+	}
+	args = args[3:]
+
+	var rotation *[2]float32
+	switch {
+	case len(args) == 0:
+	case args[0] == "facing":
+		{
+			var facing [3]float64
+			if GetArgument(args, 1) == "entity" {
+				entities := ParseTargets(executor, GetArgument(args, 2))
+				if len(entities) != 1 {
+					return chat.Text("§cUnknown player to face")
+				}
+				facing = entities[0].Position
+				if anchor := GetArgument(args, 3); anchor == "" || anchor == "eyes" {
+					facing[1] += 1.62
+				} else if anchor != "feet" {
+					return chat.Text("§cThe anchor must be either eyes or feet")
+				}
+			} else {
+				if len(args) != 4 {
+					return chat.Text("§cInvalid amount of arguments")
+				}
+				facing, err = ParseLocation(args[1:4], source, sourceRotation)
+				if err != nil {
+					return chat.Text("§c" + err.Error())
+				}
+			}
+			r := FacingRotation(position, facing)
+			rotation = &r
+		}
+	case len(args) == 2:
+		{
+			r, err := ParseRotation(args, sourceRotation)
+			if err != nil {
+				return chat.Text("§c" + err.Error())
+			}
+			rotation = &r
+		}
+	default:
+		return chat.Text("§cInvalid amount of arguments")
+	}
+
+	for _, target := range targets {
+		target.Teleport(dimension, position, rotation)
+	}
+	return chat.Text(fmt.Sprintf("Teleported %s to %.2f %.2f %.2f", targetNames, position[0], position[1], position[2]))
+}
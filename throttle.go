@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionTracker counts open connections per ip and logins that haven't finished yet
+type ConnectionTracker struct {
+	sync.Mutex
+	open      map[string]int
+	lastLogin map[string]time.Time
+	pending   int
+	lastPrune time.Time
+}
+
+var connections = &ConnectionTracker{
+	open:      make(map[string]int),
+	lastLogin: make(map[string]time.Time),
+}
+
+// registers a new connection from ip and returns the number of open connections from it
+func (tracker *ConnectionTracker) Open(ip string) int {
+	tracker.Lock()
+	defer tracker.Unlock()
+	tracker.open[ip]++
+	return tracker.open[ip]
+}
+
+func (tracker *ConnectionTracker) Close(ip string) {
+	tracker.Lock()
+	defer tracker.Unlock()
+	tracker.open[ip]--
+	if tracker.open[ip] <= 0 {
+		delete(tracker.open, ip)
+	}
+}
+
+// checks the login throttle for ip, if the login is allowed the returned function must be called once it has finished
+func (tracker *ConnectionTracker) BeginLogin(ip string) (int, func()) {
+	config := server.Config.Throttle
+	tracker.Lock()
+	defer tracker.Unlock()
+	now := time.Now()
+	interval := time.Duration(config.LoginInterval) * time.Millisecond
+	if now.Sub(tracker.lastPrune) > interval {
+		for addr, last := range tracker.lastLogin {
+			if now.Sub(last) > interval {
+				delete(tracker.lastLogin, addr)
+			}
+		}
+		tracker.lastPrune = now
+	}
+	// behind a proxy every connection comes from the proxy, which throttles players itself
+	if !ForwardingEnabled() {
+		if config.MaxConnections > 0 && tracker.open[ip] > config.MaxConnections {
+			return THROTTLE_TOO_MANY_CONNECTIONS, nil
+		}
+		last, ok := tracker.lastLogin[ip]
+		tracker.lastLogin[ip] = now
+		if ok && now.Sub(last) < interval {
+			return THROTTLE_TOO_FAST, nil
+		}
+	}
+	if config.MaxPendingLogins > 0 && tracker.pending >= config.MaxPendingLogins {
+		return THROTTLE_SERVER_BUSY, nil
+	}
+	tracker.pending++
+	var once sync.Once
+	return THROTTLE_OK, func() {
+		once.Do(func() {
+			tracker.Lock()
+			tracker.pending--
+			tracker.Unlock()
+		})
+	}
+}
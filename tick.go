@@ -1,7 +1,8 @@
 package main
 
 import (
-	"fmt"
+	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/Tnze/go-mc/data/packetid"
@@ -9,11 +10,41 @@ import (
 	pk "github.com/Tnze/go-mc/net/packet"
 )
 
+// ticks per second of the overworld, measured every second
+var tps atomic.Int64
+
+// the length of a game tick
+const TickDuration = 50 * time.Millisecond
+
 func (world World) TickLoop() {
 	var n uint
+	var ticks int64
+	second := time.Now()
+	nextTick := second.Add(TickDuration)
 	for range time.Tick(time.Microsecond * 20) {
 		world.Tick(n)
 		n++
+		if world.Name != "minecraft:overworld" {
+			continue
+		}
+		// the ticker drops ticks when the loop falls behind, so game ticks are counted against the clock
+		now := time.Now()
+		if !now.Before(nextTick) {
+			ticks++
+			nextTick = nextTick.Add(TickDuration)
+			// ticks that were missed by more than a tick are lost instead of caught up
+			if nextTick.Before(now) {
+				nextTick = now.Add(TickDuration)
+			}
+		}
+		if now.Sub(second) >= time.Second {
+			if ticks > 20 {
+				ticks = 20
+			}
+			tps.Store(ticks)
+			ticks = 0
+			second = now
+		}
 	}
 }
 
@@ -52,13 +83,13 @@ func (world World) SubtickChunkLoad(tick uint) {
 		if p.Data.Dimension != world.Name {
 			continue
 		}
-		x := int32(p.Position[0]) >> 4
-		y := int32(p.Position[1]) >> 4
-		z := int32(p.Position[2]) >> 4
+		x := int32(math.Floor(p.Position[0])) >> 4
+		y := int32(math.Floor(p.Position[1])) >> 4
+		z := int32(math.Floor(p.Position[2])) >> 4
 		if newChunkPos := [3]int32{x, y, z}; newChunkPos != p.ChunkPos {
 			p.ChunkPos = newChunkPos
 			p.Connection.WritePacket(pk.Marshal(packetid.ClientboundSetChunkCacheCenter, pk.VarInt(x), pk.VarInt(z)))
-			fmt.Println("sent packet 78 for player", p.Name)
+			server.Logger.Debug("sent packet 78 for player %s", p.Name)
 		}
 		p.LastTick = tick
 	}
@@ -82,7 +113,7 @@ LoadChunk:
 			lc.AddViewer(player.UUID.String)
 			lc.Lock()
 			player.Connection.WritePacket(pk.Marshal(packetid.ClientboundLevelChunkWithLight, level.ChunkPos(pos), lc.Chunk))
-			fmt.Println("sent packet 36 for player", player.Name, pos, len(lc.Chunk.Sections))
+			server.Logger.Debug("sent packet 36 for player %s %v %d", player.Name, pos, len(lc.Chunk.Sections))
 			lc.Unlock()
 		}
 	}
@@ -95,7 +126,7 @@ LoadChunk:
 			delete(player.LoadedChunks, pos)
 			world.Chunks[pos].RemoveViewer(player.UUID.String)
 			player.Connection.WritePacket(pk.Marshal(packetid.ClientboundForgetLevelChunk, level.ChunkPos(pos)))
-			fmt.Println("sent packet 30 for player", player.Name)
+			server.Logger.Debug("sent packet 30 for player %s", player.Name)
 		}
 	}
 	var unloadQueue [][2]int32